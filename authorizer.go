@@ -0,0 +1,132 @@
+package mbd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/ibrt/errors"
+)
+
+// CognitoClaims describes the claims of a Cognito user pool token, as provided by an API Gateway Cognito authorizer.
+type CognitoClaims struct {
+	Subject       string
+	Email         string
+	EmailVerified bool
+	Username      string
+	Groups        []string
+	Scopes        []string
+	Raw           map[string]interface{}
+}
+
+// DecodeAuthorizer decodes the authorizer values in RequestContext (e.g. a custom authorizer context) into v, which is
+// usually a pointer to a struct with JSON tags.
+func DecodeAuthorizer(ctx context.Context, v interface{}) error {
+	buf, err := json.Marshal(GetRequestContext(ctx).Authorizer)
+	if err != nil {
+		return errors.Wrap(err)
+	}
+	return errors.MaybeWrap(json.Unmarshal(buf, v))
+}
+
+// GetPrincipalID returns the principal ID returned by a custom authorizer, or "" if missing.
+func GetPrincipalID(ctx context.Context) string {
+	principalID, _ := GetRequestContext(ctx).Authorizer["principalId"].(string)
+	return principalID
+}
+
+// GetCognitoClaims returns the Cognito user pool claims in RequestContext. If missing, it returns nil.
+func GetCognitoClaims(ctx context.Context) *CognitoClaims {
+	authorizer := GetRequestContext(ctx).Authorizer
+	raw, ok := authorizer["claims"].(map[string]interface{})
+	if !ok {
+		return nil
+	}
+
+	claims := &CognitoClaims{
+		Subject:       getClaimString(raw, "sub"),
+		Email:         getClaimString(raw, "email"),
+		EmailVerified: getClaimString(raw, "email_verified") == "true",
+		Username:      getClaimString(raw, "cognito:username"),
+		Groups:        getClaimStrings(raw["cognito:groups"]),
+		Scopes:        strings.Fields(getClaimString(raw, "scope")),
+		Raw:           raw,
+	}
+
+	if claims.Username == "" {
+		claims.Username = getClaimString(raw, "username")
+	}
+
+	if scopes := getClaimStrings(authorizer["scopes"]); len(scopes) > 0 {
+		claims.Scopes = scopes
+	}
+
+	return claims
+}
+
+// RequireScopes returns a Checker that requires the Cognito claims to include all the given scopes, or fails with 403.
+func RequireScopes(scopes ...string) Checker {
+	return func(ctx context.Context, _ *events.APIGatewayProxyRequest, _ interface{}) (context.Context, error) { // Checker
+		var granted []string
+		if claims := GetCognitoClaims(ctx); claims != nil {
+			granted = claims.Scopes
+		}
+
+		for _, scope := range scopes {
+			if !containsString(granted, scope) {
+				return nil, errors.Errorf("missing scope '%v'", scope, errors.HTTPStatusForbidden)
+			}
+		}
+
+		return nil, nil
+	}
+}
+
+// RequireGroups returns a Checker that requires the Cognito claims to include at least one of the given groups, or fails
+// with 403.
+func RequireGroups(groups ...string) Checker {
+	return func(ctx context.Context, _ *events.APIGatewayProxyRequest, _ interface{}) (context.Context, error) { // Checker
+		if claims := GetCognitoClaims(ctx); claims != nil {
+			for _, group := range groups {
+				if containsString(claims.Groups, group) {
+					return nil, nil
+				}
+			}
+		}
+
+		return nil, errors.Errorf("missing any of groups %v", groups, errors.HTTPStatusForbidden)
+	}
+}
+
+func getClaimString(claims map[string]interface{}, name string) string {
+	switch v := claims[name].(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+// getClaimStrings parses a list claim, which API Gateway can provide as a list or as a string like "[a b]" or "a,b".
+func getClaimStrings(claim interface{}) []string {
+	switch v := claim.(type) {
+	case []interface{}:
+		values := make([]string, 0, len(v))
+		for _, value := range v {
+			values = append(values, fmt.Sprint(value))
+		}
+		return values
+	case []string:
+		return v
+	case string:
+		return strings.FieldsFunc(strings.Trim(v, "[]"), func(r rune) bool {
+			return r == ',' || r == ' '
+		})
+	default:
+		return nil
+	}
+}
@@ -0,0 +1,121 @@
+package mbd
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/stretchr/testify/require"
+)
+
+func newAuthorizerTestContext(authorizer map[string]interface{}) context.Context {
+	return populateContext(context.Background(), false, &events.APIGatewayProxyRequest{
+		RequestContext: events.APIGatewayProxyRequestContext{Authorizer: authorizer},
+	})
+}
+
+func TestDecodeAuthorizer(t *testing.T) {
+	ctx := newAuthorizerTestContext(map[string]interface{}{
+		"principalId": "user",
+		"tenantId":    "tenant",
+		"admin":       true,
+	})
+
+	v := &struct {
+		PrincipalID string `json:"principalId"`
+		TenantID    string `json:"tenantId"`
+		Admin       bool   `json:"admin"`
+	}{}
+	require.NoError(t, DecodeAuthorizer(ctx, v))
+	require.Equal(t, "user", v.PrincipalID)
+	require.Equal(t, "tenant", v.TenantID)
+	require.True(t, v.Admin)
+
+	require.Error(t, DecodeAuthorizer(ctx, &struct {
+		Admin string `json:"admin"`
+	}{}))
+
+	require.Equal(t, "user", GetPrincipalID(ctx))
+	require.Equal(t, "", GetPrincipalID(newAuthorizerTestContext(nil)))
+}
+
+func TestGetCognitoClaims(t *testing.T) {
+	require.Nil(t, GetCognitoClaims(newAuthorizerTestContext(nil)))
+
+	raw := map[string]interface{}{
+		"sub":              "sub",
+		"email":            "user@example.com",
+		"email_verified":   "true",
+		"cognito:username": "user",
+		"cognito:groups":   "[admin users]",
+		"scope":            "openid email",
+	}
+	require.Equal(t, &CognitoClaims{
+		Subject:       "sub",
+		Email:         "user@example.com",
+		EmailVerified: true,
+		Username:      "user",
+		Groups:        []string{"admin", "users"},
+		Scopes:        []string{"openid", "email"},
+		Raw:           raw,
+	}, GetCognitoClaims(newAuthorizerTestContext(map[string]interface{}{"claims": raw})))
+
+	claims := GetCognitoClaims(newAuthorizerTestContext(map[string]interface{}{
+		"claims": map[string]interface{}{
+			"username":       "user",
+			"email_verified": false,
+			"cognito:groups": []interface{}{"admin"},
+		},
+		"scopes": []interface{}{"read", "write"},
+	}))
+	require.Equal(t, "user", claims.Username)
+	require.False(t, claims.EmailVerified)
+	require.Equal(t, []string{"admin"}, claims.Groups)
+	require.Equal(t, []string{"read", "write"}, claims.Scopes)
+
+	require.Equal(t, []string{"a", "b"}, GetCognitoClaims(newAuthorizerTestContext(map[string]interface{}{
+		"claims": map[string]interface{}{"cognito:groups": "a,b"},
+	})).Groups)
+}
+
+func TestRequireScopesAndGroups(t *testing.T) {
+	ctx := newAuthorizerTestContext(map[string]interface{}{
+		"claims": map[string]interface{}{
+			"cognito:groups": "[admin users]",
+			"scope":          "read write",
+		},
+	})
+
+	for _, checker := range []Checker{
+		RequireScopes(),
+		RequireScopes("read"),
+		RequireScopes("read", "write"),
+		RequireGroups("other", "admin"),
+	} {
+		newCtx, err := checker(ctx, nil, nil)
+		require.NoError(t, err)
+		require.Nil(t, newCtx)
+	}
+
+	for _, checker := range []Checker{
+		RequireScopes("read", "delete"),
+		RequireGroups("other"),
+		RequireGroups(),
+	} {
+		_, err := checker(ctx, nil, nil)
+		require.Error(t, err)
+	}
+
+	_, err := RequireScopes("read")(newAuthorizerTestContext(nil), nil, nil)
+	require.Error(t, err)
+
+	f := NewFunction(nil, func(ctx context.Context, req interface{}) (interface{}, error) {
+		return nil, nil
+	}).AddCheckers(RequireGroups("admin"))
+
+	out, err := f.Handler(context.Background(), events.APIGatewayProxyRequest{})
+	require.NoError(t, err)
+	require.Equal(t, http.StatusForbidden, out.StatusCode)
+	require.JSONEq(t, `{"statusCode":403,"publicMessage":"forbidden","requestId":""}`, out.Body)
+}
@@ -0,0 +1,165 @@
+package mbd
+
+import (
+	"encoding"
+	"reflect"
+	"strconv"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/ibrt/errors"
+)
+
+const (
+	pathTag   = "path"
+	queryTag  = "query"
+	headerTag = "header"
+)
+
+var (
+	textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+	durationType        = reflect.TypeOf(time.Duration(0))
+)
+
+// hasBindings returns true if the given struct type has at least one field tagged for parameter binding.
+func hasBindings(reqType reflect.Type) bool {
+	for i := 0; i < reqType.NumField(); i++ {
+		field := reqType.Field(i)
+
+		if field.Anonymous && field.Type.Kind() == reflect.Struct && hasBindings(field.Type) {
+			return true
+		}
+
+		for _, tag := range []string{pathTag, queryTag, headerTag} {
+			if _, ok := field.Tag.Lookup(tag); ok {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// bindParameters populates fields of the given struct pointer tagged with "path", "query" or "header" using the
+// corresponding values from the request. Fields whose parameter is not present in the request are left untouched.
+func bindParameters(in *events.APIGatewayProxyRequest, req interface{}) error {
+	return bindStructParameters(
+		newSingleGet(in.PathParameters),
+		newMultiGet(in.QueryStringParameters, in.MultiValueQueryStringParameters),
+		newMultiGet(in.Headers, in.MultiValueHeaders),
+		reflect.ValueOf(req).Elem())
+}
+
+func bindStructParameters(pathParameters *singleGet, queryString, headers *multiGet, v reflect.Value) error {
+	for i := 0; i < v.NumField(); i++ {
+		field := v.Type().Field(i)
+
+		if field.Anonymous && field.Type.Kind() == reflect.Struct {
+			if err := bindStructParameters(pathParameters, queryString, headers, v.Field(i)); err != nil {
+				return err
+			}
+			continue
+		}
+
+		if field.PkgPath != "" {
+			continue
+		}
+
+		if name, ok := field.Tag.Lookup(pathTag); ok {
+			if value := pathParameters.Get(name); value != "" {
+				if err := bindValues(v.Field(i), []string{value}); err != nil {
+					return errors.Wrap(err, errors.Prefix("invalid path parameter '%v'", name), invalidParameter)
+				}
+			}
+		}
+
+		if name, ok := field.Tag.Lookup(queryTag); ok {
+			if values := queryString.GetMulti(name); len(values) > 0 {
+				if err := bindValues(v.Field(i), values); err != nil {
+					return errors.Wrap(err, errors.Prefix("invalid query parameter '%v'", name), invalidParameter)
+				}
+			}
+		}
+
+		if name, ok := field.Tag.Lookup(headerTag); ok {
+			if values := headers.GetMulti(name); len(values) > 0 {
+				if err := bindValues(v.Field(i), values); err != nil {
+					return errors.Wrap(err, errors.Prefix("invalid header '%v'", name), invalidParameter)
+				}
+			}
+		}
+	}
+
+	return nil
+}
+
+func bindValues(v reflect.Value, values []string) error {
+	if v.Kind() == reflect.Slice && !v.Type().Implements(textUnmarshalerType) && !reflect.PtrTo(v.Type()).Implements(textUnmarshalerType) {
+		slice := reflect.MakeSlice(v.Type(), len(values), len(values))
+		for i, value := range values {
+			if err := bindValue(slice.Index(i), value); err != nil {
+				return err
+			}
+		}
+		v.Set(slice)
+		return nil
+	}
+
+	return bindValue(v, values[len(values)-1])
+}
+
+func bindValue(v reflect.Value, value string) error {
+	if v.Kind() == reflect.Ptr {
+		ptr := reflect.New(v.Type().Elem())
+		if err := bindValue(ptr.Elem(), value); err != nil {
+			return err
+		}
+		v.Set(ptr)
+		return nil
+	}
+
+	if reflect.PtrTo(v.Type()).Implements(textUnmarshalerType) {
+		return v.Addr().Interface().(encoding.TextUnmarshaler).UnmarshalText([]byte(value))
+	}
+
+	if v.Type() == durationType {
+		d, err := time.ParseDuration(value)
+		if err != nil {
+			return err
+		}
+		v.SetInt(int64(d))
+		return nil
+	}
+
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(value)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return err
+		}
+		v.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		i, err := strconv.ParseInt(value, 10, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetInt(i)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		u, err := strconv.ParseUint(value, 10, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetUint(u)
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(value, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetFloat(f)
+	default:
+		return errors.Errorf("unsupported type '%v'", v.Type())
+	}
+
+	return nil
+}
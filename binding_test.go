@@ -0,0 +1,121 @@
+package mbd
+
+import (
+	"context"
+	"net/http"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/ibrt/errors"
+	"github.com/stretchr/testify/require"
+)
+
+type bindingTestEmbedded struct {
+	Tenant string `json:"-" schema:"-" header:"X-Tenant"`
+}
+
+type bindingTestRequest struct {
+	bindingTestEmbedded
+	Value    string        `json:"value" schema:"value"`
+	ID       int64         `json:"-" schema:"-" path:"id"`
+	Limit    *uint         `json:"-" schema:"-" query:"limit"`
+	Enabled  bool          `json:"-" schema:"-" query:"enabled"`
+	Tags     []string      `json:"-" schema:"-" query:"tag"`
+	Ratios   []float64     `json:"-" schema:"-" query:"ratio"`
+	Since    time.Time     `json:"-" schema:"-" query:"since"`
+	Timeout  time.Duration `json:"-" schema:"-" header:"X-Timeout"`
+	Missing  string        `json:"-" schema:"-" query:"missing"`
+	internal string        `query:"internal"`
+}
+
+func newBindingTestRequest(body string) *events.APIGatewayProxyRequest {
+	return &events.APIGatewayProxyRequest{
+		PathParameters: map[string]string{"id": "42"},
+		QueryStringParameters: map[string]string{
+			"limit":   "10",
+			"enabled": "true",
+			"tag":     "b",
+			"ratio":   "0.5",
+			"since":   "2019-01-02T03:04:05Z",
+		},
+		MultiValueQueryStringParameters: map[string][]string{
+			"limit":   {"10"},
+			"enabled": {"true"},
+			"tag":     {"a", "b"},
+			"ratio":   {"1.5", "0.5"},
+			"since":   {"2019-01-02T03:04:05Z"},
+		},
+		Headers: map[string]string{
+			"x-tenant":  "tenant",
+			"X-Timeout": "5s",
+		},
+		Body: body,
+	}
+}
+
+func requireBindingTestRequest(t *testing.T, value string, req interface{}) {
+	limit := uint(10)
+
+	require.Equal(t, &bindingTestRequest{
+		bindingTestEmbedded: bindingTestEmbedded{Tenant: "tenant"},
+		Value:               value,
+		ID:                  42,
+		Limit:               &limit,
+		Enabled:             true,
+		Tags:                []string{"a", "b"},
+		Ratios:              []float64{1.5, 0.5},
+		Since:               time.Date(2019, 1, 2, 3, 4, 5, 0, time.UTC),
+		Timeout:             5 * time.Second,
+	}, req)
+}
+
+func TestHasBindings(t *testing.T) {
+	require.True(t, hasBindings(reflect.TypeOf(bindingTestRequest{})))
+	require.True(t, hasBindings(reflect.TypeOf(struct{ bindingTestEmbedded }{})))
+	require.False(t, hasBindings(reflect.TypeOf(struct{ Value string }{})))
+	require.False(t, hasBindings(noRequestBody))
+}
+
+func TestBindParameters_JSONRequestParser(t *testing.T) {
+	req, err := JSONRequestParser()(context.Background(), reflect.TypeOf(bindingTestRequest{}), newBindingTestRequest(`{"value":"v"}`))
+	require.NoError(t, err)
+	requireBindingTestRequest(t, "v", req)
+
+	req, err = JSONRequestParser()(context.Background(), reflect.TypeOf(bindingTestRequest{}), newBindingTestRequest(""))
+	require.NoError(t, err)
+	requireBindingTestRequest(t, "", req)
+}
+
+func TestBindParameters_FormRequestParser(t *testing.T) {
+	req, err := FormRequestParser()(context.Background(), reflect.TypeOf(bindingTestRequest{}), newBindingTestRequest("value=v"))
+	require.NoError(t, err)
+	requireBindingTestRequest(t, "v", req)
+}
+
+func TestBindParameters_Errors(t *testing.T) {
+	in := newBindingTestRequest("")
+	in.PathParameters["id"] = "x"
+	_, err := JSONRequestParser()(context.Background(), reflect.TypeOf(bindingTestRequest{}), in)
+	require.EqualError(t, err, `invalid path parameter 'id': strconv.ParseInt: parsing "x": invalid syntax`)
+	require.Equal(t, http.StatusBadRequest, errors.GetHTTPStatus(err))
+	require.Equal(t, "invalid-parameter", errors.GetPublicMessage(err))
+
+	in = newBindingTestRequest("")
+	in.MultiValueQueryStringParameters["ratio"] = []string{"1", "x"}
+	_, err = JSONRequestParser()(context.Background(), reflect.TypeOf(bindingTestRequest{}), in)
+	require.EqualError(t, err, `invalid query parameter 'ratio': strconv.ParseFloat: parsing "x": invalid syntax`)
+	require.Equal(t, "invalid-parameter", errors.GetPublicMessage(err))
+
+	in = newBindingTestRequest("")
+	in.Headers["X-Timeout"] = "x"
+	_, err = JSONRequestParser()(context.Background(), reflect.TypeOf(bindingTestRequest{}), in)
+	require.EqualError(t, err, `invalid header 'X-Timeout': time: invalid duration "x"`)
+	require.Equal(t, "invalid-parameter", errors.GetPublicMessage(err))
+
+	_, err = JSONRequestParser()(context.Background(), reflect.TypeOf(struct {
+		Value map[string]string `json:"-" query:"value"`
+	}{}), &events.APIGatewayProxyRequest{QueryStringParameters: map[string]string{"value": "v"}})
+	require.EqualError(t, err, "invalid query parameter 'value': unsupported type 'map[string]string'")
+}
@@ -0,0 +1,131 @@
+package mbd
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/ibrt/errors"
+)
+
+// CachePolicy describes the caching headers of success responses. Error responses are never cached.
+type CachePolicy struct {
+	CacheControl string // Cache-Control header value, if empty the default no-cache headers are sent
+	ETag         bool   // if true, a strong ETag is computed for 200 responses and If-None-Match is honored
+}
+
+var (
+	defaultCachePolicy = &CachePolicy{}
+)
+
+// PublicCachePolicy returns a CachePolicy that allows shared caches (e.g. CloudFront) to store responses for maxAge,
+// with ETag support.
+func PublicCachePolicy(maxAge time.Duration) *CachePolicy {
+	return &CachePolicy{
+		CacheControl: fmt.Sprintf("public, max-age=%v", int(maxAge/time.Second)),
+		ETag:         true,
+	}
+}
+
+// PrivateCachePolicy returns a CachePolicy that allows only the client to store responses for maxAge, with ETag
+// support.
+func PrivateCachePolicy(maxAge time.Duration) *CachePolicy {
+	return &CachePolicy{
+		CacheControl: fmt.Sprintf("private, max-age=%v", int(maxAge/time.Second)),
+		ETag:         true,
+	}
+}
+
+// setHeaders replaces the default no-cache headers, if the CachePolicy specifies a Cache-Control value.
+func (p *CachePolicy) setHeaders(out *events.APIGatewayProxyResponse) {
+	if p.CacheControl == "" {
+		return
+	}
+
+	delete(out.Headers, "Pragma")
+	delete(out.Headers, "Expires")
+	out.Headers["Cache-Control"] = p.CacheControl
+}
+
+// applyETag sets a strong ETag on 200 responses, unless already set, and turns them into 304 responses if they match
+// the If-None-Match header of GET and HEAD requests.
+func (p *CachePolicy) applyETag(ctx context.Context, out *events.APIGatewayProxyResponse) {
+	if !p.ETag || out.StatusCode != http.StatusOK {
+		return
+	}
+
+	etag := out.Headers["ETag"]
+	if etag == "" {
+		etag = computeETag(out)
+		out.Headers["ETag"] = etag
+	}
+
+	method := GetPath(ctx).Method
+	if (method != http.MethodGet && method != http.MethodHead) || !matchIfNoneMatch(GetHeaders(ctx).Get("If-None-Match"), etag) {
+		return
+	}
+
+	out.StatusCode = http.StatusNotModified
+	out.IsBase64Encoded = false
+	out.Body = ""
+	delete(out.Headers, "Content-Type")
+}
+
+// getCachePolicy returns the CachePolicy stored in context, or the default one.
+func getCachePolicy(ctx context.Context) *CachePolicy {
+	if cachePolicy, ok := ctx.Value(cachePolicyContextKey).(*CachePolicy); ok {
+		return cachePolicy
+	}
+	return defaultCachePolicy
+}
+
+// computeETag computes a strong ETag from the response body, as sent before compression.
+func computeETag(out *events.APIGatewayProxyResponse) string {
+	body := []byte(out.Body)
+	if out.IsBase64Encoded {
+		var err error
+		body, err = base64.StdEncoding.DecodeString(out.Body)
+		errors.MaybeMustWrap(err)
+	}
+
+	sum := sha256.Sum256(body)
+	return `"` + hex.EncodeToString(sum[:16]) + `"`
+}
+
+// setETagEncoding marks the ETag of a compressed response with the content encoding, so that it differs from the one
+// of the uncompressed representation.
+func setETagEncoding(out *events.APIGatewayProxyResponse, encoding string) {
+	if etag := out.Headers["ETag"]; strings.HasSuffix(etag, `"`) && len(etag) >= 2 {
+		out.Headers["ETag"] = etag[:len(etag)-1] + "-" + encoding + `"`
+	}
+}
+
+// matchIfNoneMatch returns true if the If-None-Match header matches the given ETag, using weak comparison and ignoring
+// content encoding marks.
+func matchIfNoneMatch(ifNoneMatch, etag string) bool {
+	etag = normalizeETag(etag)
+
+	for _, candidate := range strings.Split(ifNoneMatch, ",") {
+		if candidate = strings.TrimSpace(candidate); candidate == "*" || (candidate != "" && normalizeETag(candidate) == etag) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func normalizeETag(etag string) string {
+	etag = strings.TrimPrefix(etag, "W/")
+	for _, encoding := range []string{gzipEncoding, brotliEncoding} {
+		if suffix := "-" + encoding + `"`; strings.HasSuffix(etag, suffix) {
+			return etag[:len(etag)-len(suffix)] + `"`
+		}
+	}
+	return etag
+}
@@ -0,0 +1,118 @@
+package mbd
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/ibrt/errors"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCachePolicies(t *testing.T) {
+	require.Equal(t, &CachePolicy{CacheControl: "public, max-age=300", ETag: true}, PublicCachePolicy(5*time.Minute))
+	require.Equal(t, &CachePolicy{CacheControl: "private, max-age=60", ETag: true}, PrivateCachePolicy(time.Minute))
+}
+
+func TestMatchIfNoneMatch(t *testing.T) {
+	require.True(t, matchIfNoneMatch(`"a"`, `"a"`))
+	require.True(t, matchIfNoneMatch(`"b", W/"a"`, `"a"`))
+	require.True(t, matchIfNoneMatch(`"a-gzip"`, `"a"`))
+	require.True(t, matchIfNoneMatch(`"a-br"`, `"a-gzip"`))
+	require.True(t, matchIfNoneMatch(`*`, `"a"`))
+	require.False(t, matchIfNoneMatch(``, `"a"`))
+	require.False(t, matchIfNoneMatch(`"b"`, `"a"`))
+}
+
+func TestFunction_CachePolicy(t *testing.T) {
+	f := NewFunction(nil, func(ctx context.Context, req interface{}) (interface{}, error) {
+		switch GetPath(ctx).Path {
+		case "/error":
+			return nil, errors.Errorf("test error", errors.HTTPStatusConflict)
+		case "/override":
+			return NewResponse(http.StatusOK, map[string]string{"k": "v"}).SetCachePolicy(&CachePolicy{}), nil
+		case "/created":
+			return NewResponse(http.StatusCreated, map[string]string{"k": "v"}), nil
+		default:
+			return map[string]string{"k": "v"}, nil
+		}
+	})
+
+	out, err := f.Handler(context.Background(), events.APIGatewayProxyRequest{HTTPMethod: http.MethodGet})
+	require.NoError(t, err)
+	require.Equal(t, "no-cache, no-store, must-revalidate", out.Headers["Cache-Control"])
+	require.Equal(t, "no-cache", out.Headers["Pragma"])
+	require.Equal(t, "0", out.Headers["Expires"])
+	require.Empty(t, out.Headers["ETag"])
+
+	f.SetCachePolicy(PublicCachePolicy(time.Minute))
+
+	out, err = f.Handler(context.Background(), events.APIGatewayProxyRequest{HTTPMethod: http.MethodGet})
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, out.StatusCode)
+	require.Equal(t, "public, max-age=60", out.Headers["Cache-Control"])
+	require.Empty(t, out.Headers["Pragma"])
+	require.Empty(t, out.Headers["Expires"])
+	require.Regexp(t, `^"[0-9a-f]{32}"$`, out.Headers["ETag"])
+	etag := out.Headers["ETag"]
+
+	out, err = f.Handler(context.Background(), events.APIGatewayProxyRequest{
+		HTTPMethod: http.MethodGet,
+		Headers:    map[string]string{"If-None-Match": etag},
+	})
+	require.NoError(t, err)
+	require.Equal(t, http.StatusNotModified, out.StatusCode)
+	require.Equal(t, "", out.Body)
+	require.Equal(t, etag, out.Headers["ETag"])
+	require.Equal(t, "public, max-age=60", out.Headers["Cache-Control"])
+	require.Empty(t, out.Headers["Content-Type"])
+
+	out, err = f.Handler(context.Background(), events.APIGatewayProxyRequest{
+		HTTPMethod: http.MethodPost,
+		Headers:    map[string]string{"If-None-Match": etag},
+	})
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, out.StatusCode)
+
+	out, err = f.Handler(context.Background(), events.APIGatewayProxyRequest{HTTPMethod: http.MethodGet, Path: "/error"})
+	require.NoError(t, err)
+	require.Equal(t, http.StatusConflict, out.StatusCode)
+	require.Equal(t, "no-cache, no-store, must-revalidate", out.Headers["Cache-Control"])
+	require.Empty(t, out.Headers["ETag"])
+
+	out, err = f.Handler(context.Background(), events.APIGatewayProxyRequest{HTTPMethod: http.MethodGet, Path: "/override"})
+	require.NoError(t, err)
+	require.Equal(t, "no-cache, no-store, must-revalidate", out.Headers["Cache-Control"])
+	require.Empty(t, out.Headers["ETag"])
+
+	out, err = f.Handler(context.Background(), events.APIGatewayProxyRequest{HTTPMethod: http.MethodGet, Path: "/created"})
+	require.NoError(t, err)
+	require.Equal(t, http.StatusCreated, out.StatusCode)
+	require.Equal(t, "public, max-age=60", out.Headers["Cache-Control"])
+	require.Empty(t, out.Headers["ETag"])
+}
+
+func TestFunction_CachePolicy_Compression(t *testing.T) {
+	f := NewFunction(nil, func(ctx context.Context, req interface{}) (interface{}, error) {
+		return map[string]string{"k": strings.Repeat("v", 100)}, nil
+	}).SetCachePolicy(PublicCachePolicy(time.Minute)).SetCompression(true, 10)
+
+	out, err := f.Handler(context.Background(), events.APIGatewayProxyRequest{
+		HTTPMethod: http.MethodGet,
+		Headers:    map[string]string{"Accept-Encoding": "gzip"},
+	})
+	require.NoError(t, err)
+	require.Equal(t, "gzip", out.Headers["Content-Encoding"])
+	require.Regexp(t, `^"[0-9a-f]{32}-gzip"$`, out.Headers["ETag"])
+
+	out, err = f.Handler(context.Background(), events.APIGatewayProxyRequest{
+		HTTPMethod: http.MethodGet,
+		Headers:    map[string]string{"Accept-Encoding": "gzip", "If-None-Match": out.Headers["ETag"]},
+	})
+	require.NoError(t, err)
+	require.Equal(t, http.StatusNotModified, out.StatusCode)
+	require.Empty(t, out.Headers["Content-Encoding"])
+}
@@ -0,0 +1,144 @@
+package mbd
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/base64"
+	"io"
+	"io/ioutil"
+	"strconv"
+	"strings"
+
+	"github.com/andybalholm/brotli"
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/ibrt/errors"
+)
+
+const (
+	gzipEncoding   = "gzip"
+	brotliEncoding = "br"
+)
+
+// decompressRequest decodes gzip or brotli encoded request bodies, according to the Content-Encoding header. If maxSize
+// is positive, larger decoded bodies are rejected.
+func decompressRequest(ctx context.Context, in *events.APIGatewayProxyRequest, maxSize int64) error {
+	encoding := strings.ToLower(strings.TrimSpace(GetHeaders(ctx).Get("Content-Encoding")))
+
+	var newReader func(io.Reader) (io.Reader, error)
+
+	switch encoding {
+	case "", "identity":
+		return nil
+	case gzipEncoding, "x-gzip":
+		newReader = func(r io.Reader) (io.Reader, error) { return gzip.NewReader(r) }
+	case brotliEncoding:
+		newReader = func(r io.Reader) (io.Reader, error) { return brotli.NewReader(r), nil }
+	default:
+		return errors.Errorf("unsupported Content-Encoding '%v'", encoding, invalidContentEncoding)
+	}
+
+	body, err := getBody(in)
+	if err != nil {
+		return err
+	}
+
+	r, err := newReader(bytes.NewReader(body))
+	if err != nil {
+		return errors.Wrap(err, errors.Prefix("invalid %v Body", encoding), invalidBody)
+	}
+
+	if maxSize > 0 {
+		r = io.LimitReader(r, maxSize+1)
+	}
+
+	body, err = ioutil.ReadAll(r)
+	if err != nil {
+		return errors.Wrap(err, errors.Prefix("invalid %v Body", encoding), invalidBody)
+	}
+	if maxSize > 0 && int64(len(body)) > maxSize {
+		return errors.Errorf("decoded %v Body too large: expected at most %v bytes", encoding, maxSize, bodyTooLarge)
+	}
+
+	in.Body = string(body)
+	in.IsBase64Encoded = false
+	return nil
+}
+
+// compressResponse compresses the response body if it is at least minSize bytes and the request Accept-Encoding header
+// allows gzip or brotli. The compressed body is base64 encoded.
+func compressResponse(ctx context.Context, out *events.APIGatewayProxyResponse, minSize int) error {
+	if out.Body == "" || out.Headers["Content-Encoding"] != "" {
+		return nil
+	}
+
+	body := []byte(out.Body)
+	if out.IsBase64Encoded {
+		var err error
+		if body, err = base64.StdEncoding.DecodeString(out.Body); err != nil {
+			return errors.Wrap(err, errors.Prefix("invalid base64 response body"))
+		}
+	}
+
+	if len(body) < minSize {
+		return nil
+	}
+
+	addVary(out, "Accept-Encoding")
+
+	encoding := negotiateEncoding(strings.Join(GetHeaders(ctx).GetMulti("Accept-Encoding"), ","))
+	if encoding == "" {
+		return nil
+	}
+
+	buf := &bytes.Buffer{}
+	var w io.WriteCloser
+
+	if encoding == brotliEncoding {
+		w = brotli.NewWriter(buf)
+	} else {
+		w = gzip.NewWriter(buf)
+	}
+
+	if _, err := w.Write(body); err != nil {
+		return errors.Wrap(err)
+	}
+	if err := w.Close(); err != nil {
+		return errors.Wrap(err)
+	}
+
+	out.Headers["Content-Encoding"] = encoding
+	setETagEncoding(out, encoding)
+	out.IsBase64Encoded = true
+	out.Body = base64.StdEncoding.EncodeToString(buf.Bytes())
+	return nil
+}
+
+// negotiateEncoding returns the preferred supported encoding in the given Accept-Encoding header, or "" if none.
+func negotiateEncoding(acceptEncoding string) string {
+	best, bestQ := "", 0.0
+
+	for _, part := range strings.Split(acceptEncoding, ",") {
+		params := strings.Split(part, ";")
+		encoding := strings.ToLower(strings.TrimSpace(params[0]))
+		q := 1.0
+
+		for _, param := range params[1:] {
+			if kv := strings.SplitN(strings.TrimSpace(param), "=", 2); len(kv) == 2 && kv[0] == "q" {
+				if parsed, err := strconv.ParseFloat(kv[1], 64); err == nil {
+					q = parsed
+				}
+			}
+		}
+
+		if encoding == "*" {
+			encoding = brotliEncoding
+		}
+
+		if (encoding == gzipEncoding || encoding == brotliEncoding) && q > 0 && (q > bestQ || (q == bestQ && encoding == brotliEncoding)) {
+			best, bestQ = encoding, q
+		}
+	}
+
+	return best
+}
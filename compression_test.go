@@ -0,0 +1,173 @@
+package mbd
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/base64"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/andybalholm/brotli"
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/ibrt/errors"
+	"github.com/stretchr/testify/require"
+)
+
+func newCompressionTestFunction() *Function {
+	return NewFunction(compressionTestRequest{}, func(ctx context.Context, req interface{}) (interface{}, error) {
+		return &compressionTestRequest{Value: req.(*compressionTestRequest).Value}, nil
+	}).SetResponseEncoders(CompactJSONResponseEncoder()).SetCompression(true, 20)
+}
+
+type compressionTestRequest struct {
+	Value string `json:"value"`
+}
+
+func gzipCompressionTestBody(t *testing.T, body string) string {
+	buf := &bytes.Buffer{}
+	w := gzip.NewWriter(buf)
+	_, err := w.Write([]byte(body))
+	require.NoError(t, err)
+	require.NoError(t, w.Close())
+	return base64.StdEncoding.EncodeToString(buf.Bytes())
+}
+
+func TestNegotiateEncoding(t *testing.T) {
+	require.Equal(t, "", negotiateEncoding(""))
+	require.Equal(t, "", negotiateEncoding("deflate, identity"))
+	require.Equal(t, "gzip", negotiateEncoding("gzip"))
+	require.Equal(t, "br", negotiateEncoding("gzip, deflate, br"))
+	require.Equal(t, "gzip", negotiateEncoding("br;q=0.5, GZIP;q=0.8"))
+	require.Equal(t, "gzip", negotiateEncoding("br;q=0, gzip"))
+	require.Equal(t, "br", negotiateEncoding("*"))
+}
+
+func TestFunction_Compression(t *testing.T) {
+	value := strings.Repeat("a", 100)
+
+	out, err := newCompressionTestFunction().Handler(context.Background(), events.APIGatewayProxyRequest{
+		Headers: map[string]string{"Accept-Encoding": "gzip"},
+		Body:    `{"value":"` + value + `"}`,
+	})
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, out.StatusCode)
+	require.Equal(t, "gzip", out.Headers["Content-Encoding"])
+	require.Equal(t, "Accept-Encoding", out.Headers["Vary"])
+	require.True(t, out.IsBase64Encoded)
+
+	buf, err := base64.StdEncoding.DecodeString(out.Body)
+	require.NoError(t, err)
+	r, err := gzip.NewReader(bytes.NewReader(buf))
+	require.NoError(t, err)
+	buf, err = ioutil.ReadAll(r)
+	require.NoError(t, err)
+	require.Equal(t, `{"value":"`+value+`"}`, string(buf))
+
+	out, err = newCompressionTestFunction().Handler(context.Background(), events.APIGatewayProxyRequest{
+		Headers: map[string]string{"Accept-Encoding": "gzip, br"},
+		Body:    `{"value":"` + value + `"}`,
+	})
+	require.NoError(t, err)
+	require.Equal(t, "br", out.Headers["Content-Encoding"])
+
+	buf, err = base64.StdEncoding.DecodeString(out.Body)
+	require.NoError(t, err)
+	buf, err = ioutil.ReadAll(brotli.NewReader(bytes.NewReader(buf)))
+	require.NoError(t, err)
+	require.Equal(t, `{"value":"`+value+`"}`, string(buf))
+}
+
+func TestFunction_Compression_Skipped(t *testing.T) {
+	out, err := newCompressionTestFunction().Handler(context.Background(), events.APIGatewayProxyRequest{
+		Headers: map[string]string{"Accept-Encoding": "gzip"},
+		Body:    `{"value":"a"}`,
+	})
+	require.NoError(t, err)
+	require.Equal(t, `{"value":"a"}`, out.Body)
+	require.Empty(t, out.Headers["Content-Encoding"])
+	require.Empty(t, out.Headers["Vary"])
+	require.False(t, out.IsBase64Encoded)
+
+	out, err = newCompressionTestFunction().Handler(context.Background(), events.APIGatewayProxyRequest{
+		Body: `{"value":"` + strings.Repeat("a", 100) + `"}`,
+	})
+	require.NoError(t, err)
+	require.Empty(t, out.Headers["Content-Encoding"])
+	require.Equal(t, "Accept-Encoding", out.Headers["Vary"])
+	require.False(t, out.IsBase64Encoded)
+}
+
+func TestFunction_Compression_InvalidBase64(t *testing.T) {
+	f := NewFunction(nil, func(ctx context.Context, req interface{}) (interface{}, error) {
+		return &SerializedResponse{
+			ContentType:     "application/octet-stream",
+			IsBase64Encoded: true,
+			Body:            "not base64!",
+		}, nil
+	}).SetCompression(true, 0)
+
+	out, err := f.Handler(context.Background(), events.APIGatewayProxyRequest{
+		Headers: map[string]string{"Accept-Encoding": "gzip"},
+	})
+	require.NoError(t, err)
+	require.Equal(t, http.StatusInternalServerError, out.StatusCode)
+	require.Empty(t, out.Headers["Content-Encoding"])
+	require.JSONEq(t, `{"statusCode":500,"publicMessage":"internal-server-error","requestId":""}`, out.Body)
+}
+
+func TestFunction_Decompression(t *testing.T) {
+	out, err := newCompressionTestFunction().Handler(context.Background(), events.APIGatewayProxyRequest{
+		Headers:         map[string]string{"Content-Encoding": "gzip"},
+		IsBase64Encoded: true,
+		Body:            gzipCompressionTestBody(t, `{"value":"a"}`),
+	})
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, out.StatusCode)
+	require.Equal(t, `{"value":"a"}`, out.Body)
+}
+
+func TestFunction_Decompression_Limit(t *testing.T) {
+	body := gzipCompressionTestBody(t, `{"value":"`+strings.Repeat("a", 1<<20)+`"}`)
+	require.True(t, len(body) < 10<<10)
+
+	f := newCompressionTestFunction().SetDecompressionLimit(1 << 20)
+	out, err := f.Handler(context.Background(), events.APIGatewayProxyRequest{
+		Headers:         map[string]string{"Content-Encoding": "gzip"},
+		IsBase64Encoded: true,
+		Body:            body,
+	})
+	require.NoError(t, err)
+	require.Equal(t, http.StatusRequestEntityTooLarge, out.StatusCode)
+	require.JSONEq(t, `{"statusCode":413,"publicMessage":"body-too-large","requestId":""}`, out.Body)
+
+	in := &events.APIGatewayProxyRequest{Headers: map[string]string{"Content-Encoding": "gzip"}, IsBase64Encoded: true, Body: body}
+	err = decompressRequest(populateContext(context.Background(), false, in), in, 1<<20+12)
+	require.NoError(t, err)
+	require.Len(t, in.Body, 1<<20+12)
+
+	in = &events.APIGatewayProxyRequest{Headers: map[string]string{"Content-Encoding": "gzip"}, IsBase64Encoded: true, Body: body}
+	err = decompressRequest(populateContext(context.Background(), false, in), in, 1<<20+11)
+	require.EqualError(t, err, "decoded gzip Body too large: expected at most 1048587 bytes")
+	require.Equal(t, http.StatusRequestEntityTooLarge, errors.GetHTTPStatus(err))
+}
+
+func TestDecompressRequest_Errors(t *testing.T) {
+	in := &events.APIGatewayProxyRequest{Headers: map[string]string{"Content-Encoding": "deflate"}, Body: "x"}
+	err := decompressRequest(populateContext(context.Background(), false, in), in, 0)
+	require.EqualError(t, err, "unsupported Content-Encoding 'deflate'")
+	require.Equal(t, http.StatusUnsupportedMediaType, errors.GetHTTPStatus(err))
+	require.Equal(t, "invalid-content-encoding", errors.GetPublicMessage(err))
+
+	in = &events.APIGatewayProxyRequest{Headers: map[string]string{"Content-Encoding": "gzip"}, Body: "x"}
+	err = decompressRequest(populateContext(context.Background(), false, in), in, 0)
+	require.EqualError(t, err, "invalid gzip Body: unexpected EOF")
+	require.Equal(t, http.StatusBadRequest, errors.GetHTTPStatus(err))
+	require.Equal(t, "invalid-body", errors.GetPublicMessage(err))
+
+	in = &events.APIGatewayProxyRequest{Headers: map[string]string{"Content-Encoding": "gzip"}, IsBase64Encoded: true, Body: "-"}
+	err = decompressRequest(populateContext(context.Background(), false, in), in, 0)
+	require.EqualError(t, err, "invalid base64 Body: illegal base64 data at input byte 0")
+}
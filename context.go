@@ -16,6 +16,17 @@ const (
 	pathParametersContextKey
 	stageVariablesContextKey
 	requestContextContextKey
+	responseEncoderContextKey
+	errorRendererContextKey
+	errorMappersContextKey
+	errorReportingContextKey
+	loggerContextKey
+	accessLogContextKey
+	metricsContextKey
+	spanContextKey
+	cachePolicyContextKey
+	jwtClaimsContextKey
+	deadlineStateContextKey
 )
 
 func populateContext(ctx context.Context, debug Debug, in *events.APIGatewayProxyRequest) context.Context {
@@ -0,0 +1,122 @@
+package mbd
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+// CORSConfig describes a Cross-Origin Resource Sharing configuration.
+type CORSConfig struct {
+	AllowedOrigins   []string      // e.g. "https://example.com", "https://*.example.com" or "*"
+	AllowedMethods   []string      // defaults to GET, HEAD, POST, PUT, PATCH, DELETE
+	AllowedHeaders   []string      // defaults to the headers requested by the preflight request
+	ExposedHeaders   []string      // response headers readable by the client, other than the CORS-safelisted ones
+	AllowCredentials bool          // if true, credentialed requests are allowed, cannot be combined with "*" origins
+	MaxAge           time.Duration // how long preflight responses can be cached, 0 to omit
+}
+
+var (
+	defaultCORSAllowedMethods = []string{
+		http.MethodGet,
+		http.MethodHead,
+		http.MethodPost,
+		http.MethodPut,
+		http.MethodPatch,
+		http.MethodDelete,
+	}
+)
+
+// isPreflightRequest returns true if the request is a CORS preflight request.
+func isPreflightRequest(method string, headers *multiGet) bool {
+	return strings.EqualFold(method, http.MethodOptions) &&
+		headers.Get("Origin") != "" &&
+		headers.Get("Access-Control-Request-Method") != ""
+}
+
+// newPreflightResponse returns a response to a CORS preflight request. CORS headers are omitted if the origin is not
+// allowed, causing the browser to block the actual request.
+func (c *CORSConfig) newPreflightResponse(ctx context.Context) *events.APIGatewayProxyResponse {
+	out := newResponse(http.StatusNoContent)
+	delete(out.Headers, "Content-Type")
+	addVary(out, "Origin")
+	addVary(out, "Access-Control-Request-Method")
+	addVary(out, "Access-Control-Request-Headers")
+
+	if !c.setAllowOrigin(ctx, out) {
+		return out
+	}
+
+	allowedMethods := c.AllowedMethods
+	if len(allowedMethods) == 0 {
+		allowedMethods = defaultCORSAllowedMethods
+	}
+	out.Headers["Access-Control-Allow-Methods"] = strings.Join(allowedMethods, ", ")
+
+	if len(c.AllowedHeaders) > 0 {
+		out.Headers["Access-Control-Allow-Headers"] = strings.Join(c.AllowedHeaders, ", ")
+	} else if requestedHeaders := GetHeaders(ctx).Get("Access-Control-Request-Headers"); requestedHeaders != "" {
+		out.Headers["Access-Control-Allow-Headers"] = requestedHeaders
+	}
+
+	if c.MaxAge > 0 {
+		out.Headers["Access-Control-Max-Age"] = strconv.Itoa(int(c.MaxAge / time.Second))
+	}
+
+	return out
+}
+
+// decorate adds CORS headers to a success or error response.
+func (c *CORSConfig) decorate(ctx context.Context, out *events.APIGatewayProxyResponse) {
+	if out.Headers == nil {
+		out.Headers = make(map[string]string)
+	}
+	addVary(out, "Origin")
+
+	if c.setAllowOrigin(ctx, out) && len(c.ExposedHeaders) > 0 {
+		out.Headers["Access-Control-Expose-Headers"] = strings.Join(c.ExposedHeaders, ", ")
+	}
+}
+
+// setAllowOrigin sets the Access-Control-Allow-Origin and Access-Control-Allow-Credentials headers, if the request
+// origin is allowed. It returns true if the origin is allowed.
+func (c *CORSConfig) setAllowOrigin(ctx context.Context, out *events.APIGatewayProxyResponse) bool {
+	origin := GetHeaders(ctx).Get("Origin")
+	if origin == "" {
+		return false
+	}
+
+	for _, allowedOrigin := range c.AllowedOrigins {
+		if !matchOrigin(allowedOrigin, origin) {
+			continue
+		}
+
+		if allowedOrigin == "*" {
+			out.Headers["Access-Control-Allow-Origin"] = "*"
+		} else {
+			out.Headers["Access-Control-Allow-Origin"] = origin
+		}
+
+		if c.AllowCredentials {
+			out.Headers["Access-Control-Allow-Credentials"] = "true"
+		}
+
+		return true
+	}
+
+	return false
+}
+
+// matchOrigin matches an origin against a pattern, which can contain a single "*" wildcard.
+func matchOrigin(pattern, origin string) bool {
+	if i := strings.Index(pattern, "*"); i >= 0 {
+		prefix, suffix := strings.ToLower(pattern[:i]), strings.ToLower(pattern[i+1:])
+		origin = strings.ToLower(origin)
+		return len(origin) >= len(prefix)+len(suffix) && strings.HasPrefix(origin, prefix) && strings.HasSuffix(origin, suffix)
+	}
+	return strings.EqualFold(pattern, origin)
+}
@@ -0,0 +1,121 @@
+package mbd
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/ibrt/errors"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMatchOrigin(t *testing.T) {
+	require.True(t, matchOrigin("*", "https://example.com"))
+	require.True(t, matchOrigin("https://example.com", "https://EXAMPLE.com"))
+	require.True(t, matchOrigin("https://*.example.com", "https://www.example.com"))
+	require.True(t, matchOrigin("https://*.example.com", "https://a.b.example.com"))
+	require.False(t, matchOrigin("https://*.example.com", "https://example.com"))
+	require.False(t, matchOrigin("https://*.example.com", "https://www.example.org"))
+	require.False(t, matchOrigin("https://example.com", "http://example.com"))
+}
+
+func TestFunction_CORS_Preflight(t *testing.T) {
+	f := NewFunction(nil, func(ctx context.Context, req interface{}) (interface{}, error) {
+		panic("handler invoked")
+	}).SetCORS(&CORSConfig{
+		AllowedOrigins:   []string{"https://*.example.com"},
+		AllowedMethods:   []string{"GET", "POST"},
+		AllowCredentials: true,
+		MaxAge:           time.Hour,
+	})
+
+	out, err := f.Handler(context.Background(), events.APIGatewayProxyRequest{
+		HTTPMethod: http.MethodOptions,
+		Headers: map[string]string{
+			"Origin":                         "https://www.example.com",
+			"Access-Control-Request-Method":  "POST",
+			"Access-Control-Request-Headers": "Content-Type, X-Custom",
+		},
+	})
+	require.NoError(t, err)
+	require.Equal(t, http.StatusNoContent, out.StatusCode)
+	require.Equal(t, "", out.Body)
+	require.Equal(t, map[string]string{
+		"Access-Control-Allow-Origin":      "https://www.example.com",
+		"Access-Control-Allow-Credentials": "true",
+		"Access-Control-Allow-Methods":     "GET, POST",
+		"Access-Control-Allow-Headers":     "Content-Type, X-Custom",
+		"Access-Control-Max-Age":           "3600",
+		"Vary":                             "Origin, Access-Control-Request-Method, Access-Control-Request-Headers",
+		"Cache-Control":                    "no-cache, no-store, must-revalidate",
+		"Pragma":                           "no-cache",
+		"Expires":                          "0",
+	}, out.Headers)
+
+	out, err = f.Handler(context.Background(), events.APIGatewayProxyRequest{
+		HTTPMethod: http.MethodOptions,
+		Headers: map[string]string{
+			"Origin":                        "https://www.example.org",
+			"Access-Control-Request-Method": "POST",
+		},
+	})
+	require.NoError(t, err)
+	require.Equal(t, http.StatusNoContent, out.StatusCode)
+	require.Empty(t, out.Headers["Access-Control-Allow-Origin"])
+	require.Empty(t, out.Headers["Access-Control-Allow-Methods"])
+}
+
+func TestFunction_CORS(t *testing.T) {
+	f := NewFunction(nil, func(ctx context.Context, req interface{}) (interface{}, error) {
+		if GetPath(ctx).Path == "/error" {
+			return nil, errors.Errorf("test error", errors.HTTPStatusConflict)
+		}
+		return &SerializedResponse{ContentType: "text/plain", Body: "ok"}, nil
+	}).SetCORS(&CORSConfig{
+		AllowedOrigins: []string{"*"},
+		ExposedHeaders: []string{"X-Custom"},
+		AllowedHeaders: []string{"Content-Type"},
+	})
+
+	for _, path := range []string{"/ok", "/error"} {
+		out, err := f.Handler(context.Background(), events.APIGatewayProxyRequest{
+			Path:       path,
+			HTTPMethod: http.MethodGet,
+			Headers:    map[string]string{"Origin": "https://example.com"},
+		})
+		require.NoError(t, err)
+		require.Equal(t, "*", out.Headers["Access-Control-Allow-Origin"])
+		require.Equal(t, "X-Custom", out.Headers["Access-Control-Expose-Headers"])
+		require.Equal(t, "Origin", out.Headers["Vary"])
+		require.Empty(t, out.Headers["Access-Control-Allow-Credentials"])
+	}
+
+	out, err := f.Handler(context.Background(), events.APIGatewayProxyRequest{
+		HTTPMethod: http.MethodGet,
+	})
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, out.StatusCode)
+	require.Empty(t, out.Headers["Access-Control-Allow-Origin"])
+	require.Equal(t, "Origin", out.Headers["Vary"])
+
+	out, err = f.Handler(context.Background(), events.APIGatewayProxyRequest{
+		HTTPMethod: http.MethodOptions,
+		Headers: map[string]string{
+			"Origin":                        "https://example.com",
+			"Access-Control-Request-Method": "PUT",
+		},
+	})
+	require.NoError(t, err)
+	require.Equal(t, http.StatusNoContent, out.StatusCode)
+	require.Equal(t, "GET, HEAD, POST, PUT, PATCH, DELETE", out.Headers["Access-Control-Allow-Methods"])
+	require.Equal(t, "Content-Type", out.Headers["Access-Control-Allow-Headers"])
+	require.Empty(t, out.Headers["Access-Control-Max-Age"])
+
+	require.Panics(t, func() { f.SetCORS(&CORSConfig{}) })
+	require.Panics(t, func() { f.SetCORS(&CORSConfig{AllowedOrigins: []string{"*"}, AllowCredentials: true}) })
+	require.NotPanics(t, func() {
+		f.SetCORS(&CORSConfig{AllowedOrigins: []string{"https://*.example.com"}, AllowCredentials: true})
+	})
+}
@@ -0,0 +1,144 @@
+package mbd
+
+import (
+	"context"
+	stderrors "errors"
+	"net/http"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-lambda-go/lambda"
+	"github.com/ibrt/errors"
+)
+
+// AuthorizerRequest is the input of a custom authorizer. It supports both REQUEST authorizers, which receive the request
+// metadata, and TOKEN authorizers, which only receive AuthorizationToken and MethodArn.
+type AuthorizerRequest struct {
+	events.APIGatewayCustomAuthorizerRequestTypeRequest
+	AuthorizationToken string `json:"authorizationToken"`
+}
+
+// AuthorizerHandler implements a custom authorizer handler. Returning an error with HTTP status 401 causes API Gateway
+// to respond 401, returning a Policy that denies the method causes it to respond 403, and other errors cause it to
+// respond 500.
+type AuthorizerHandler func(ctx context.Context, req *AuthorizerRequest) (*Policy, error)
+
+// Authorizer sets up a Lambda custom authorizer handler, the counterpart to Function. The request metadata is
+// available from the context through the usual getters (e.g. GetHeaders, GetQueryString, GetRequestContext).
+type Authorizer struct {
+	handler   AuthorizerHandler
+	debug     Debug
+	providers []Provider
+}
+
+var (
+	errUnauthorized = stderrors.New("Unauthorized")
+)
+
+// NewAuthorizer initializes a new Authorizer.
+func NewAuthorizer(handler AuthorizerHandler) *Authorizer {
+	errors.Assert(handler != nil, "handler must not be nil")
+
+	return &Authorizer{
+		handler:   handler,
+		debug:     false,
+		providers: make([]Provider, 0),
+	}
+}
+
+// SetDebug enables or disables additional debug information. Default is disabled.
+func (a *Authorizer) SetDebug(debug Debug) *Authorizer {
+	a.debug = debug
+	return a
+}
+
+// AddProviders adds one or more Provider(s) to the Authorizer.
+func (a *Authorizer) AddProviders(providers ...Provider) *Authorizer {
+	a.providers = append(a.providers, providers...)
+	return a
+}
+
+// Handler provides a handler function suitable for lambda.Start().
+func (a *Authorizer) Handler(ctx context.Context, in AuthorizerRequest) (resp events.APIGatewayCustomAuthorizerResponse, err error) {
+	defer func() {
+		if rErr := errors.MaybeWrapRecover(recover()); rErr != nil {
+			resp, err = events.APIGatewayCustomAuthorizerResponse{}, rErr
+		}
+	}()
+
+	methodARN, err := ParseMethodARN(in.MethodArn)
+	if err != nil {
+		return events.APIGatewayCustomAuthorizerResponse{}, err
+	}
+
+	ctx = populateContext(ctx, a.debug, newAuthorizerProxyRequest(&in, methodARN))
+
+	for _, provider := range a.providers {
+		ctx = provider(ctx)
+	}
+
+	policy, err := a.handler(ctx, &in)
+	if err != nil {
+		if errors.GetHTTPStatus(err) == http.StatusUnauthorized {
+			return events.APIGatewayCustomAuthorizerResponse{}, errUnauthorized
+		}
+		return events.APIGatewayCustomAuthorizerResponse{}, err
+	}
+
+	errors.Assert(policy != nil, "handler must return a non-nil Policy or an error")
+	return policy.toResponse(methodARN), nil
+}
+
+// Start invokes lambda.Start() passing the Authorizer handler as argument.
+func (a *Authorizer) Start() {
+	lambda.Start(a.Handler)
+}
+
+// newAuthorizerProxyRequest converts an AuthorizerRequest to a proxy request, completing the missing fields of TOKEN
+// authorizer requests from the method ARN.
+func newAuthorizerProxyRequest(in *AuthorizerRequest, methodARN *MethodARN) *events.APIGatewayProxyRequest {
+	proxyReq := &events.APIGatewayProxyRequest{
+		Resource:                        in.Resource,
+		Path:                            in.Path,
+		HTTPMethod:                      in.HTTPMethod,
+		Headers:                         in.Headers,
+		MultiValueHeaders:               in.MultiValueHeaders,
+		QueryStringParameters:           in.QueryStringParameters,
+		MultiValueQueryStringParameters: in.MultiValueQueryStringParameters,
+		PathParameters:                  in.PathParameters,
+		StageVariables:                  in.StageVariables,
+		RequestContext: events.APIGatewayProxyRequestContext{
+			AccountID:    in.RequestContext.AccountID,
+			ResourceID:   in.RequestContext.ResourceID,
+			Stage:        in.RequestContext.Stage,
+			RequestID:    in.RequestContext.RequestID,
+			ResourcePath: in.RequestContext.ResourcePath,
+			HTTPMethod:   in.RequestContext.HTTPMethod,
+			APIID:        in.RequestContext.APIID,
+			Identity: events.APIGatewayRequestIdentity{
+				APIKey:   in.RequestContext.Identity.APIKey,
+				SourceIP: in.RequestContext.Identity.SourceIP,
+			},
+		},
+	}
+
+	if proxyReq.Path == "" {
+		proxyReq.Path = "/" + methodARN.Resource
+	}
+	if proxyReq.HTTPMethod == "" {
+		proxyReq.HTTPMethod = methodARN.Method
+	}
+	if proxyReq.RequestContext.AccountID == "" {
+		proxyReq.RequestContext.AccountID = methodARN.AccountID
+	}
+	if proxyReq.RequestContext.Stage == "" {
+		proxyReq.RequestContext.Stage = methodARN.Stage
+	}
+	if proxyReq.RequestContext.APIID == "" {
+		proxyReq.RequestContext.APIID = methodARN.APIID
+	}
+	if proxyReq.RequestContext.HTTPMethod == "" {
+		proxyReq.RequestContext.HTTPMethod = proxyReq.HTTPMethod
+	}
+
+	return proxyReq
+}
@@ -0,0 +1,104 @@
+package mbd
+
+import (
+	"context"
+	"testing"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/ibrt/errors"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAuthorizer_Request(t *testing.T) {
+	a := NewAuthorizer(func(ctx context.Context, req *AuthorizerRequest) (*Policy, error) {
+		require.Equal(t, "REQUEST", req.Type)
+		require.Equal(t, "test-value", ctx.Value("k"))
+		require.Equal(t, "/users/{id}", GetPath(ctx).Resource)
+		require.Equal(t, "/users/1", GetPath(ctx).Path)
+		require.Equal(t, "GET", GetPath(ctx).Method)
+		require.Equal(t, "1", GetPathParameters(ctx).Get("id"))
+		require.Equal(t, "v", GetQueryString(ctx).Get("q"))
+		require.Equal(t, "127.0.0.1", GetRequestContext(ctx).Identity.SourceIP)
+		require.Equal(t, "prod", GetRequestContext(ctx).Stage)
+
+		switch GetHeaders(ctx).Get("authorization") {
+		case "allow":
+			return NewPolicy("user").AllowAll(), nil
+		case "panic":
+			panic("test panic")
+		case "error":
+			return nil, errors.Errorf("test error")
+		default:
+			return nil, errors.Errorf("invalid token", errors.HTTPStatusUnauthorized)
+		}
+	}).AddProviders(SingletonProvider("k", "test-value")).SetDebug(true)
+
+	newRequest := func(authorization string) AuthorizerRequest {
+		return AuthorizerRequest{
+			APIGatewayCustomAuthorizerRequestTypeRequest: events.APIGatewayCustomAuthorizerRequestTypeRequest{
+				Type:                  "REQUEST",
+				MethodArn:             "arn:aws:execute-api:us-east-1:123456789012:abcdef1234/prod/GET/users/1",
+				Resource:              "/users/{id}",
+				Path:                  "/users/1",
+				HTTPMethod:            "GET",
+				Headers:               map[string]string{"Authorization": authorization},
+				QueryStringParameters: map[string]string{"q": "v"},
+				PathParameters:        map[string]string{"id": "1"},
+				RequestContext: events.APIGatewayCustomAuthorizerRequestTypeRequestContext{
+					Stage:    "prod",
+					Identity: events.APIGatewayCustomAuthorizerRequestTypeRequestIdentity{SourceIP: "127.0.0.1"},
+				},
+			},
+		}
+	}
+
+	resp, err := a.Handler(context.Background(), newRequest("allow"))
+	require.NoError(t, err)
+	require.Equal(t, "user", resp.PrincipalID)
+	require.Equal(t, "Allow", resp.PolicyDocument.Statement[0].Effect)
+
+	_, err = a.Handler(context.Background(), newRequest("invalid"))
+	require.EqualError(t, err, "Unauthorized")
+
+	_, err = a.Handler(context.Background(), newRequest("error"))
+	require.EqualError(t, err, "test error")
+
+	_, err = a.Handler(context.Background(), newRequest("panic"))
+	require.EqualError(t, err, "test panic")
+}
+
+func TestAuthorizer_Token(t *testing.T) {
+	a := NewAuthorizer(func(ctx context.Context, req *AuthorizerRequest) (*Policy, error) {
+		require.Equal(t, "TOKEN", req.Type)
+		require.Equal(t, "test-token", req.AuthorizationToken)
+		require.Equal(t, "/users/1", GetPath(ctx).Path)
+		require.Equal(t, "GET", GetPath(ctx).Method)
+		require.Equal(t, "123456789012", GetRequestContext(ctx).AccountID)
+		require.Equal(t, "abcdef1234", GetRequestContext(ctx).APIID)
+		require.Equal(t, "prod", GetRequestContext(ctx).Stage)
+		require.Equal(t, "", GetHeaders(ctx).Get("Authorization"))
+		return NewPolicy("user").Allow("GET", "/users/*"), nil
+	})
+
+	resp, err := a.Handler(context.Background(), AuthorizerRequest{
+		APIGatewayCustomAuthorizerRequestTypeRequest: events.APIGatewayCustomAuthorizerRequestTypeRequest{
+			Type:      "TOKEN",
+			MethodArn: "arn:aws:execute-api:us-east-1:123456789012:abcdef1234/prod/GET/users/1",
+		},
+		AuthorizationToken: "test-token",
+	})
+	require.NoError(t, err)
+	require.Equal(t, []string{"arn:aws:execute-api:us-east-1:123456789012:abcdef1234/prod/GET/users/*"}, resp.PolicyDocument.Statement[0].Resource)
+
+	_, err = a.Handler(context.Background(), AuthorizerRequest{AuthorizationToken: "test-token"})
+	require.EqualError(t, err, "invalid method ARN ''")
+
+	_, err = NewAuthorizer(func(ctx context.Context, req *AuthorizerRequest) (*Policy, error) {
+		return nil, nil
+	}).Handler(context.Background(), AuthorizerRequest{
+		APIGatewayCustomAuthorizerRequestTypeRequest: events.APIGatewayCustomAuthorizerRequestTypeRequest{
+			MethodArn: "arn:aws:execute-api:us-east-1:123456789012:abcdef1234/prod/GET/users/1",
+		},
+	})
+	require.EqualError(t, err, "handler must return a non-nil Policy or an error")
+}
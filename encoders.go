@@ -0,0 +1,394 @@
+package mbd
+
+import (
+	"bytes"
+	"context"
+	"encoding"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/csv"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"math"
+	"mime"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/ibrt/errors"
+)
+
+// ResponseEncoder encodes response bodies for a given media type.
+type ResponseEncoder interface {
+	// MediaType returns the media type matched against the request Accept header, e.g. "application/json".
+	MediaType() string
+	// Encode encodes the given response.
+	Encode(resp interface{}) (*SerializedResponse, error)
+}
+
+type responseEncoder struct {
+	mediaType string
+	encode    func(resp interface{}) (*SerializedResponse, error)
+}
+
+// NewResponseEncoder initializes a new ResponseEncoder for the given media type using the given encode function.
+func NewResponseEncoder(mediaType string, encode func(resp interface{}) (*SerializedResponse, error)) ResponseEncoder {
+	errors.Assert(mediaType != "", "mediaType must not be empty")
+	errors.Assert(encode != nil, "encode must not be nil")
+
+	return &responseEncoder{
+		mediaType: strings.ToLower(mediaType),
+		encode:    encode,
+	}
+}
+
+// MediaType implements ResponseEncoder.
+func (e *responseEncoder) MediaType() string {
+	return e.mediaType
+}
+
+// Encode implements ResponseEncoder.
+func (e *responseEncoder) Encode(resp interface{}) (*SerializedResponse, error) {
+	return e.encode(resp)
+}
+
+var (
+	defaultResponseEncoder = JSONResponseEncoder()
+	textMarshalerType      = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+)
+
+// JSONResponseEncoder returns a ResponseEncoder for indented JSON. It is the default.
+func JSONResponseEncoder() ResponseEncoder {
+	return NewResponseEncoder("application/json", func(resp interface{}) (*SerializedResponse, error) {
+		buf, err := json.MarshalIndent(resp, "", "  ")
+		if err != nil {
+			return nil, errors.Wrap(err)
+		}
+		return &SerializedResponse{ContentType: "application/json; charset=utf-8", Body: string(buf)}, nil
+	})
+}
+
+// CompactJSONResponseEncoder returns a ResponseEncoder for compact JSON.
+func CompactJSONResponseEncoder() ResponseEncoder {
+	return NewResponseEncoder("application/json", func(resp interface{}) (*SerializedResponse, error) {
+		buf, err := json.Marshal(resp)
+		if err != nil {
+			return nil, errors.Wrap(err)
+		}
+		return &SerializedResponse{ContentType: "application/json; charset=utf-8", Body: string(buf)}, nil
+	})
+}
+
+// XMLResponseEncoder returns a ResponseEncoder for XML, using encoding/xml.
+func XMLResponseEncoder() ResponseEncoder {
+	return NewResponseEncoder("application/xml", func(resp interface{}) (*SerializedResponse, error) {
+		buf, err := xml.MarshalIndent(resp, "", "  ")
+		if err != nil {
+			return nil, errors.Wrap(err)
+		}
+		return &SerializedResponse{ContentType: "application/xml; charset=utf-8", Body: xml.Header + string(buf)}, nil
+	})
+}
+
+// MessagePackResponseEncoder returns a ResponseEncoder for MessagePack. Values are first converted to their JSON
+// representation, so that field names and custom marshalers are consistent with the JSON encoders.
+func MessagePackResponseEncoder() ResponseEncoder {
+	return NewResponseEncoder("application/msgpack", func(resp interface{}) (*SerializedResponse, error) {
+		buf, err := json.Marshal(resp)
+		if err != nil {
+			return nil, errors.Wrap(err)
+		}
+
+		dec := json.NewDecoder(bytes.NewReader(buf))
+		dec.UseNumber()
+
+		var v interface{}
+		if err := dec.Decode(&v); err != nil {
+			return nil, errors.Wrap(err)
+		}
+
+		out := &bytes.Buffer{}
+		encodeMessagePack(out, v)
+
+		return &SerializedResponse{
+			ContentType:     "application/msgpack",
+			IsBase64Encoded: true,
+			Body:            base64.StdEncoding.EncodeToString(out.Bytes()),
+		}, nil
+	})
+}
+
+// CSVResponseEncoder returns a ResponseEncoder for CSV. It supports structs and slices of structs (or pointers to
+// structs), encoded with a header row named after the JSON field names, and [][]string values.
+func CSVResponseEncoder() ResponseEncoder {
+	return NewResponseEncoder("text/csv", func(resp interface{}) (*SerializedResponse, error) {
+		records, err := getCSVRecords(resp)
+		if err != nil {
+			return nil, err
+		}
+
+		buf := &bytes.Buffer{}
+		w := csv.NewWriter(buf)
+		if err := w.WriteAll(records); err != nil {
+			return nil, errors.Wrap(err)
+		}
+
+		return &SerializedResponse{ContentType: "text/csv; charset=utf-8", Body: buf.String()}, nil
+	})
+}
+
+func getCSVRecords(resp interface{}) ([][]string, error) {
+	if records, ok := resp.([][]string); ok {
+		return records, nil
+	}
+
+	v := reflect.ValueOf(resp)
+	if !v.IsValid() {
+		return nil, errors.Errorf("unsupported CSV type '%T'", resp)
+	}
+
+	rows := []reflect.Value{v}
+	if v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			v = reflect.Zero(v.Type().Elem())
+			rows = nil // encoded as the header row only
+		} else {
+			v = v.Elem()
+			rows = []reflect.Value{v}
+		}
+	}
+
+	if v.Kind() == reflect.Slice || v.Kind() == reflect.Array {
+		rows = make([]reflect.Value, v.Len())
+		for i := 0; i < v.Len(); i++ {
+			rows[i] = v.Index(i)
+		}
+	}
+
+	elemType := v.Type()
+	if v.Kind() == reflect.Slice || v.Kind() == reflect.Array {
+		elemType = v.Type().Elem()
+	}
+	if elemType.Kind() == reflect.Ptr {
+		elemType = elemType.Elem()
+	}
+	if elemType.Kind() != reflect.Struct {
+		return nil, errors.Errorf("unsupported CSV type '%v'", v.Type())
+	}
+
+	fields := make([]int, 0, elemType.NumField())
+	header := make([]string, 0, elemType.NumField())
+
+	for i := 0; i < elemType.NumField(); i++ {
+		field := elemType.Field(i)
+		if field.PkgPath != "" {
+			continue
+		}
+
+		name := strings.Split(field.Tag.Get("json"), ",")[0]
+		if name == "-" {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+
+		fields = append(fields, i)
+		header = append(header, name)
+	}
+
+	records := [][]string{header}
+
+	for _, row := range rows {
+		row = reflect.Indirect(row)
+		record := make([]string, len(fields))
+
+		if row.IsValid() {
+			for i, field := range fields {
+				value, err := formatCSVValue(row.Field(field))
+				if err != nil {
+					return nil, err
+				}
+				record[i] = value
+			}
+		}
+
+		records = append(records, record)
+	}
+
+	return records, nil
+}
+
+func formatCSVValue(v reflect.Value) (string, error) {
+	if (v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface) && v.IsNil() {
+		return "", nil
+	}
+
+	if v.Type().Implements(textMarshalerType) {
+		buf, err := v.Interface().(encoding.TextMarshaler).MarshalText()
+		if err != nil {
+			return "", errors.Wrap(err)
+		}
+		return string(buf), nil
+	}
+
+	v = reflect.Indirect(v)
+
+	switch v.Kind() {
+	case reflect.String, reflect.Bool,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return fmt.Sprint(v.Interface()), nil
+	default:
+		buf, err := json.Marshal(v.Interface())
+		if err != nil {
+			return "", errors.Wrap(err)
+		}
+		return string(buf), nil
+	}
+}
+
+func encodeMessagePack(buf *bytes.Buffer, v interface{}) {
+	switch v := v.(type) {
+	case nil:
+		buf.WriteByte(0xc0)
+	case bool:
+		if v {
+			buf.WriteByte(0xc3)
+		} else {
+			buf.WriteByte(0xc2)
+		}
+	case json.Number:
+		if i, err := strconv.ParseInt(string(v), 10, 64); err == nil {
+			buf.WriteByte(0xd3)
+			errors.MaybeMustWrap(binary.Write(buf, binary.BigEndian, i))
+			return
+		}
+		f, err := v.Float64()
+		errors.MaybeMustWrap(err)
+		buf.WriteByte(0xcb)
+		errors.MaybeMustWrap(binary.Write(buf, binary.BigEndian, math.Float64bits(f)))
+	case string:
+		encodeMessagePackHeader(buf, len(v), 0xa0, 31, 0xd9, 0xda, 0xdb)
+		buf.WriteString(v)
+	case []interface{}:
+		encodeMessagePackHeader(buf, len(v), 0x90, 15, 0, 0xdc, 0xdd)
+		for _, e := range v {
+			encodeMessagePack(buf, e)
+		}
+	case map[string]interface{}:
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+
+		encodeMessagePackHeader(buf, len(v), 0x80, 15, 0, 0xde, 0xdf)
+		for _, k := range keys {
+			encodeMessagePack(buf, k)
+			encodeMessagePack(buf, v[k])
+		}
+	default:
+		errors.MustErrorf("unexpected type '%T'", v)
+	}
+}
+
+func encodeMessagePackHeader(buf *bytes.Buffer, n int, fixPrefix byte, fixMax int, prefix8, prefix16, prefix32 byte) {
+	switch {
+	case n <= fixMax:
+		buf.WriteByte(fixPrefix | byte(n))
+	case prefix8 != 0 && n <= math.MaxUint8:
+		buf.WriteByte(prefix8)
+		buf.WriteByte(byte(n))
+	case n <= math.MaxUint16:
+		buf.WriteByte(prefix16)
+		errors.MaybeMustWrap(binary.Write(buf, binary.BigEndian, uint16(n)))
+	default:
+		buf.WriteByte(prefix32)
+		errors.MaybeMustWrap(binary.Write(buf, binary.BigEndian, uint32(n)))
+	}
+}
+
+type acceptRange struct {
+	mediaType string
+	q         float64
+}
+
+// negotiateResponseEncoder returns the ResponseEncoder best matching the given Accept header, or nil if none is
+// acceptable. The first encoder is returned if the Accept header is empty.
+func negotiateResponseEncoder(accept string, encoders []ResponseEncoder) ResponseEncoder {
+	mediaTypes := make([]string, len(encoders))
+	for i, encoder := range encoders {
+		mediaTypes[i] = encoder.MediaType()
+	}
+
+	if i := negotiateMediaType(accept, mediaTypes); i >= 0 {
+		return encoders[i]
+	}
+	return nil
+}
+
+// negotiateMediaType returns the index of the media type best matching the given Accept header, or -1 if none is
+// acceptable. The first media type is returned if the Accept header is empty.
+func negotiateMediaType(accept string, mediaTypes []string) int {
+	if strings.TrimSpace(accept) == "" {
+		return 0
+	}
+
+	ranges := make([]*acceptRange, 0)
+
+	for _, part := range strings.Split(accept, ",") {
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+
+		q := 1.0
+		if qParam, ok := params["q"]; ok {
+			if q, err = strconv.ParseFloat(qParam, 64); err != nil {
+				continue
+			}
+		}
+
+		if q > 0 {
+			ranges = append(ranges, &acceptRange{mediaType: mediaType, q: q})
+		}
+	}
+
+	sort.SliceStable(ranges, func(i, j int) bool {
+		if ranges[i].q != ranges[j].q {
+			return ranges[i].q > ranges[j].q
+		}
+		return strings.Count(ranges[i].mediaType, "*") < strings.Count(ranges[j].mediaType, "*")
+	})
+
+	for _, r := range ranges {
+		for i, mediaType := range mediaTypes {
+			if matchMediaRange(r.mediaType, mediaType) {
+				return i
+			}
+		}
+	}
+
+	return -1
+}
+
+func matchMediaRange(mediaRange, mediaType string) bool {
+	if mediaRange == "*/*" || mediaRange == mediaType {
+		return true
+	}
+	if strings.HasSuffix(mediaRange, "/*") {
+		return strings.HasPrefix(mediaType, strings.TrimSuffix(mediaRange, "*"))
+	}
+	return false
+}
+
+func getResponseEncoder(ctx context.Context) ResponseEncoder {
+	if encoder, ok := ctx.Value(responseEncoderContextKey).(ResponseEncoder); ok {
+		return encoder
+	}
+	return defaultResponseEncoder
+}
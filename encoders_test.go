@@ -0,0 +1,189 @@
+package mbd
+
+import (
+	"context"
+	"encoding/base64"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/ibrt/errors"
+	"github.com/stretchr/testify/require"
+)
+
+type encodersTestRow struct {
+	Name    string     `json:"name"`
+	Count   *int       `json:"count,omitempty"`
+	Time    time.Time  `json:"time"`
+	Tags    []string   `json:"tags"`
+	Ignored string     `json:"-"`
+	Other   bool       `json:",omitempty"`
+	Ptr     *time.Time `json:"ptr"`
+}
+
+func TestJSONResponseEncoders(t *testing.T) {
+	resp, err := JSONResponseEncoder().Encode(map[string]string{"k": "v"})
+	require.NoError(t, err)
+	require.Equal(t, &SerializedResponse{ContentType: "application/json; charset=utf-8", Body: "{\n  \"k\": \"v\"\n}"}, resp)
+
+	resp, err = CompactJSONResponseEncoder().Encode(map[string]string{"k": "v"})
+	require.NoError(t, err)
+	require.Equal(t, &SerializedResponse{ContentType: "application/json; charset=utf-8", Body: `{"k":"v"}`}, resp)
+
+	_, err = JSONResponseEncoder().Encode(func() {})
+	require.Error(t, err)
+}
+
+func TestXMLResponseEncoder(t *testing.T) {
+	resp, err := XMLResponseEncoder().Encode(&struct {
+		XMLName struct{} `xml:"resp"`
+		Value   string   `xml:"value"`
+	}{Value: "v"})
+	require.NoError(t, err)
+	require.Equal(t, &SerializedResponse{
+		ContentType: "application/xml; charset=utf-8",
+		Body:        "<?xml version=\"1.0\" encoding=\"UTF-8\"?>\n<resp>\n  <value>v</value>\n</resp>",
+	}, resp)
+
+	_, err = XMLResponseEncoder().Encode(map[string]string{})
+	require.Error(t, err)
+}
+
+func TestMessagePackResponseEncoder(t *testing.T) {
+	resp, err := MessagePackResponseEncoder().Encode(map[string]interface{}{
+		"a": nil,
+		"b": true,
+		"c": 1,
+		"d": 1.5,
+		"e": []interface{}{false},
+	})
+	require.NoError(t, err)
+	require.Equal(t, "application/msgpack", resp.ContentType)
+	require.True(t, resp.IsBase64Encoded)
+
+	buf, err := base64.StdEncoding.DecodeString(resp.Body)
+	require.NoError(t, err)
+	require.Equal(t, []byte{
+		0x85,
+		0xa1, 'a', 0xc0,
+		0xa1, 'b', 0xc3,
+		0xa1, 'c', 0xd3, 0, 0, 0, 0, 0, 0, 0, 1,
+		0xa1, 'd', 0xcb, 0x3f, 0xf8, 0, 0, 0, 0, 0, 0,
+		0xa1, 'e', 0x91, 0xc2,
+	}, buf)
+}
+
+func TestCSVResponseEncoder(t *testing.T) {
+	count := 2
+	rows := []*encodersTestRow{
+		{Name: "a", Count: &count, Time: time.Date(2019, 1, 2, 3, 4, 5, 0, time.UTC), Tags: []string{"x", "y"}, Ignored: "i"},
+		nil,
+		{Name: "b,c", Other: true},
+	}
+
+	resp, err := CSVResponseEncoder().Encode(rows)
+	require.NoError(t, err)
+	require.Equal(t, &SerializedResponse{
+		ContentType: "text/csv; charset=utf-8",
+		Body: "name,count,time,tags,Other,ptr\n" +
+			"a,2,2019-01-02T03:04:05Z,\"[\"\"x\"\",\"\"y\"\"]\",false,\n" +
+			",,,,,\n" +
+			"\"b,c\",,0001-01-01T00:00:00Z,null,true,\n",
+	}, resp)
+
+	resp, err = CSVResponseEncoder().Encode(encodersTestRow{Name: "a"})
+	require.NoError(t, err)
+	require.Equal(t, "name,count,time,tags,Other,ptr\na,,0001-01-01T00:00:00Z,null,false,\n", resp.Body)
+
+	resp, err = CSVResponseEncoder().Encode([][]string{{"a", "b"}, {"1", "2"}})
+	require.NoError(t, err)
+	require.Equal(t, "a,b\n1,2\n", resp.Body)
+
+	resp, err = CSVResponseEncoder().Encode((*encodersTestRow)(nil))
+	require.NoError(t, err)
+	require.Equal(t, "name,count,time,tags,Other,ptr\n", resp.Body)
+
+	resp, err = CSVResponseEncoder().Encode((*[]encodersTestRow)(nil))
+	require.NoError(t, err)
+	require.Equal(t, "name,count,time,tags,Other,ptr\n", resp.Body)
+
+	_, err = CSVResponseEncoder().Encode([]string{"a"})
+	require.EqualError(t, err, "unsupported CSV type '[]string'")
+
+	_, err = CSVResponseEncoder().Encode(nil)
+	require.EqualError(t, err, "unsupported CSV type '<nil>'")
+}
+
+func TestNegotiateResponseEncoder(t *testing.T) {
+	jsonEncoder := JSONResponseEncoder()
+	xmlEncoder := XMLResponseEncoder()
+	csvEncoder := CSVResponseEncoder()
+	encoders := []ResponseEncoder{jsonEncoder, xmlEncoder, csvEncoder}
+
+	require.Equal(t, jsonEncoder, negotiateResponseEncoder("", encoders))
+	require.Equal(t, jsonEncoder, negotiateResponseEncoder("*/*", encoders))
+	require.Equal(t, xmlEncoder, negotiateResponseEncoder("application/xml", encoders))
+	require.Equal(t, xmlEncoder, negotiateResponseEncoder("text/html, application/xml;q=0.9, */*;q=0.8", encoders))
+	require.Equal(t, csvEncoder, negotiateResponseEncoder("text/*", encoders))
+	require.Equal(t, csvEncoder, negotiateResponseEncoder("application/json;q=0.5, text/csv", encoders))
+	require.Equal(t, csvEncoder, negotiateResponseEncoder("*/*;q=0.5, text/csv;q=0.5", encoders))
+	require.Equal(t, jsonEncoder, negotiateResponseEncoder("invalid;;, application/json", encoders))
+	require.Nil(t, negotiateResponseEncoder("text/html", encoders))
+	require.Nil(t, negotiateResponseEncoder("application/json;q=0", encoders))
+	require.Nil(t, negotiateResponseEncoder("application/json;q=x", encoders))
+}
+
+func TestFunction_ResponseEncoders(t *testing.T) {
+	f := NewFunction(nil, func(ctx context.Context, req interface{}) (interface{}, error) {
+		return [][]string{{"a"}}, nil
+	}).SetResponseEncoders(JSONResponseEncoder(), CSVResponseEncoder()).SetCachePolicy(PublicCachePolicy(time.Minute))
+
+	out, err := f.Handler(context.Background(), events.APIGatewayProxyRequest{
+		Headers: map[string]string{"Accept": "text/csv"},
+	})
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, out.StatusCode)
+	require.Equal(t, "text/csv; charset=utf-8", out.Headers["Content-Type"])
+	require.Equal(t, "Accept", out.Headers["Vary"])
+	require.Equal(t, "a\n", out.Body)
+
+	out, err = f.Handler(context.Background(), events.APIGatewayProxyRequest{
+		Headers: map[string]string{"Accept": "text/html"},
+	})
+	require.NoError(t, err)
+	require.Equal(t, http.StatusNotAcceptable, out.StatusCode)
+	require.Equal(t, "application/json; charset=utf-8", out.Headers["Content-Type"])
+	require.Equal(t, "Accept", out.Headers["Vary"])
+	require.JSONEq(t, `{"statusCode":406,"publicMessage":"not-acceptable","requestId":""}`, out.Body)
+}
+
+func TestFunction_ResponseEncoders_Error(t *testing.T) {
+	f := NewFunction(nil, func(ctx context.Context, req interface{}) (interface{}, error) {
+		return nil, nil
+	}).SetResponseEncoders(JSONResponseEncoder(), XMLResponseEncoder(), CSVResponseEncoder())
+
+	out, err := f.Handler(context.Background(), events.APIGatewayProxyRequest{
+		Headers: map[string]string{"Accept": "application/xml"},
+		Body:    "unexpected",
+	})
+	require.NoError(t, err)
+	require.Equal(t, http.StatusBadRequest, out.StatusCode)
+	require.Equal(t, "application/xml; charset=utf-8", out.Headers["Content-Type"])
+	require.Contains(t, out.Body, "<PublicMessage>unexpected-body</PublicMessage>")
+
+	f = NewFunction(nil, func(ctx context.Context, req interface{}) (interface{}, error) {
+		return nil, nil
+	}).SetResponseEncoders(NewResponseEncoder("text/plain", func(resp interface{}) (*SerializedResponse, error) {
+		return nil, errors.Errorf("cannot encode")
+	}))
+
+	out, err = f.Handler(context.Background(), events.APIGatewayProxyRequest{
+		Body: "unexpected",
+	})
+	require.NoError(t, err)
+	require.Equal(t, http.StatusBadRequest, out.StatusCode)
+	require.Equal(t, "application/json; charset=utf-8", out.Headers["Content-Type"])
+	require.JSONEq(t, `{"statusCode":400,"publicMessage":"unexpected-body","requestId":""}`, out.Body)
+	require.Empty(t, out.Headers["Vary"])
+}
@@ -0,0 +1,139 @@
+package mbd
+
+import (
+	"context"
+	"database/sql"
+	"encoding/xml"
+	stderrors "errors"
+	"reflect"
+	"sort"
+
+	"github.com/ibrt/errors"
+)
+
+// ErrorCode returns a Behavior that stores a stable, machine-readable error code in the error metadata.
+// It is included in ErrorResponse regardless of the debug flag, so it must not contain private information.
+func ErrorCode(code string) errors.Behavior {
+	return errors.Metadata(reflect.ValueOf(ErrorCode), code)
+}
+
+// GetErrorCode extracts an error code from the error metadata, if any.
+// It returns "" if no error code was set.
+func GetErrorCode(err error) string {
+	if code, ok := errors.GetMetadata(err, reflect.ValueOf(ErrorCode)).(string); ok {
+		return code
+	}
+	return ""
+}
+
+// ErrorDetail returns a Behavior that adds a public detail (e.g. a conflicting resource ID) to the error metadata.
+// Details are included in ErrorResponse regardless of the debug flag, so they must not contain private information.
+func ErrorDetail(key string, value interface{}) errors.Behavior {
+	return func(doubleWrap bool, err error) {
+		details := make(map[string]interface{})
+		for k, v := range GetErrorDetails(err) {
+			details[k] = v
+		}
+		details[key] = value
+		errors.Metadata(reflect.ValueOf(ErrorDetail), details)(doubleWrap, err)
+	}
+}
+
+// GetErrorDetails extracts the public details from the error metadata, if any.
+// It returns nil if no details were set.
+func GetErrorDetails(err error) map[string]interface{} {
+	if details, ok := errors.GetMetadata(err, reflect.ValueOf(ErrorDetail)).(map[string]interface{}); ok {
+		return details
+	}
+	return nil
+}
+
+// ErrorHeader returns a Behavior that adds a header (e.g. WWW-Authenticate) to the error metadata. Headers are set on
+// the error response after it has been rendered.
+func ErrorHeader(key, value string) errors.Behavior {
+	return func(doubleWrap bool, err error) {
+		headers := make(map[string]string)
+		for k, v := range GetErrorHeaders(err) {
+			headers[k] = v
+		}
+		headers[key] = value
+		errors.Metadata(reflect.ValueOf(ErrorHeader), headers)(doubleWrap, err)
+	}
+}
+
+// GetErrorHeaders extracts the headers from the error metadata, if any.
+// It returns nil if no headers were set.
+func GetErrorHeaders(err error) map[string]string {
+	if headers, ok := errors.GetMetadata(err, reflect.ValueOf(ErrorHeader)).(map[string]string); ok {
+		return headers
+	}
+	return nil
+}
+
+// ErrorDetails is the Details section of ErrorResponse.
+type ErrorDetails map[string]interface{}
+
+// MarshalXML implements xml.Marshaler, encoding each detail as an element named after its key.
+func (d ErrorDetails) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
+	if len(d) == 0 {
+		return nil
+	}
+
+	keys := make([]string, 0, len(d))
+	for k := range d {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	if err := e.EncodeToken(start); err != nil {
+		return errors.Wrap(err)
+	}
+
+	for _, k := range keys {
+		if err := e.EncodeElement(d[k], xml.StartElement{Name: xml.Name{Local: k}}); err != nil {
+			return errors.Wrap(err)
+		}
+	}
+
+	return errors.MaybeWrap(e.EncodeToken(start.End()))
+}
+
+// ErrorMapper maps an error, e.g. returned by a third-party library, to an error carrying an HTTP status, public message
+// and other behaviors. It returns nil if it does not handle the given error.
+type ErrorMapper func(ctx context.Context, err error) error
+
+// MapError returns an ErrorMapper that applies the given behaviors to errors equal to target, also when target is
+// wrapped using errors.Wrap() or fmt.Errorf("%w").
+func MapError(target error, behaviors ...errors.Behavior) ErrorMapper {
+	return func(ctx context.Context, err error) error {
+		if errors.Equals(err, target) || stderrors.Is(errors.Unwrap(err), target) {
+			return errors.Wrap(err, behaviors...)
+		}
+		return nil
+	}
+}
+
+// StandardErrorMappers returns ErrorMapper(s) for common standard library errors: sql.ErrNoRows maps to 404 and
+// context.DeadlineExceeded maps to 504.
+func StandardErrorMappers() []ErrorMapper {
+	return []ErrorMapper{
+		MapError(sql.ErrNoRows, errors.HTTPStatusNotFound, errors.PublicMessage("not-found")),
+		MapError(context.DeadlineExceeded, timeout),
+	}
+}
+
+// mapError applies the first matching ErrorMapper in the context, unless the error already carries an HTTP status.
+func mapError(ctx context.Context, err error) error {
+	if errors.GetHTTPStatus(err) != 0 {
+		return err
+	}
+
+	errorMappers, _ := ctx.Value(errorMappersContextKey).([]ErrorMapper)
+	for _, errorMapper := range errorMappers {
+		if mappedErr := errorMapper(ctx, err); mappedErr != nil {
+			return mappedErr
+		}
+	}
+
+	return err
+}
@@ -0,0 +1,139 @@
+package mbd
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/ibrt/errors"
+	"github.com/stretchr/testify/require"
+)
+
+func TestErrorCode(t *testing.T) {
+	require.Equal(t, "", GetErrorCode(fmt.Errorf("test error")))
+	require.Equal(t, "", GetErrorCode(errors.Errorf("test error")))
+	require.Equal(t, "test-code", GetErrorCode(errors.Errorf("test error", ErrorCode("test-code"))))
+}
+
+func TestErrorDetail(t *testing.T) {
+	require.Nil(t, GetErrorDetails(fmt.Errorf("test error")))
+	require.Nil(t, GetErrorDetails(errors.Errorf("test error")))
+
+	err := errors.Errorf("test error", ErrorDetail("k1", "v1"), ErrorDetail("k2", 2))
+	require.Equal(t, map[string]interface{}{"k1": "v1", "k2": 2}, GetErrorDetails(err))
+
+	err = errors.Wrap(err, ErrorDetail("k1", "v3"))
+	require.Equal(t, map[string]interface{}{"k1": "v3", "k2": 2}, GetErrorDetails(err))
+}
+
+func TestErrorDetails_MarshalXML(t *testing.T) {
+	buf, err := xml.Marshal(&struct {
+		XMLName struct{}     `xml:"resp"`
+		Details ErrorDetails `xml:"details"`
+	}{Details: ErrorDetails{"b": 2, "a": "v"}})
+	require.NoError(t, err)
+	require.Equal(t, "<resp><details><a>v</a><b>2</b></details></resp>", string(buf))
+
+	buf, err = xml.Marshal(&struct {
+		XMLName struct{}     `xml:"resp"`
+		Details ErrorDetails `xml:"details"`
+	}{})
+	require.NoError(t, err)
+	require.Equal(t, "<resp></resp>", string(buf))
+}
+
+func TestFunction_ErrorCodeAndDetails(t *testing.T) {
+	f := NewFunction(nil, func(ctx context.Context, req interface{}) (interface{}, error) {
+		return nil, errors.Errorf("private message",
+			errors.HTTPStatusConflict,
+			ErrorCode("resource-conflict"),
+			ErrorDetail("conflictingId", "123"))
+	})
+
+	out, err := f.Handler(context.Background(), events.APIGatewayProxyRequest{
+		RequestContext: events.APIGatewayProxyRequestContext{RequestID: "test"},
+	})
+	require.NoError(t, err)
+	require.Equal(t, http.StatusConflict, out.StatusCode)
+
+	errResp := &ErrorResponse{}
+	require.NoError(t, json.Unmarshal([]byte(out.Body), errResp))
+	require.Equal(t, &ErrorResponse{
+		StatusCode:    http.StatusConflict,
+		PublicMessage: "conflict",
+		Code:          "resource-conflict",
+		Details:       ErrorDetails{"conflictingId": "123"},
+		RequestID:     "test",
+	}, errResp)
+	require.NotContains(t, out.Body, "private message")
+}
+
+func TestMapError(t *testing.T) {
+	errMapper := MapError(sql.ErrNoRows, errors.HTTPStatusNotFound, errors.PublicMessage("not-found"))
+
+	require.Nil(t, errMapper(context.Background(), fmt.Errorf("test error")))
+
+	for _, err := range []error{
+		sql.ErrNoRows,
+		errors.Wrap(sql.ErrNoRows),
+		fmt.Errorf("test error: %w", sql.ErrNoRows),
+		errors.Append(errors.Errorf("test error"), sql.ErrNoRows),
+	} {
+		mappedErr := errMapper(context.Background(), err)
+		require.Error(t, mappedErr)
+		require.Equal(t, http.StatusNotFound, errors.GetHTTPStatus(mappedErr))
+		require.Equal(t, "not-found", errors.GetPublicMessage(mappedErr))
+	}
+}
+
+func TestFunction_ErrorMappers(t *testing.T) {
+	var handlerErr error
+
+	f := NewFunction(nil, func(ctx context.Context, req interface{}) (interface{}, error) {
+		return nil, handlerErr
+	}).AddErrorMappers(StandardErrorMappers()...)
+
+	handlerErr = errors.Wrap(sql.ErrNoRows)
+	out, err := f.Handler(context.Background(), events.APIGatewayProxyRequest{})
+	require.NoError(t, err)
+	require.Equal(t, http.StatusNotFound, out.StatusCode)
+	require.JSONEq(t, `{"statusCode":404,"publicMessage":"not-found","requestId":""}`, out.Body)
+
+	handlerErr = fmt.Errorf("test error: %w", context.DeadlineExceeded)
+	out, err = f.Handler(context.Background(), events.APIGatewayProxyRequest{})
+	require.NoError(t, err)
+	require.Equal(t, http.StatusGatewayTimeout, out.StatusCode)
+	require.JSONEq(t, `{"statusCode":504,"publicMessage":"timeout","requestId":""}`, out.Body)
+
+	handlerErr = errors.Wrap(sql.ErrNoRows, errors.HTTPStatusConflict)
+	out, err = f.Handler(context.Background(), events.APIGatewayProxyRequest{})
+	require.NoError(t, err)
+	require.Equal(t, http.StatusConflict, out.StatusCode)
+
+	handlerErr = errors.Errorf("test error")
+	out, err = f.Handler(context.Background(), events.APIGatewayProxyRequest{})
+	require.NoError(t, err)
+	require.Equal(t, http.StatusInternalServerError, out.StatusCode)
+}
+
+func TestErrorHeader(t *testing.T) {
+	require.Nil(t, GetErrorHeaders(fmt.Errorf("test error")))
+
+	err := errors.Errorf("test error", ErrorHeader("k1", "v1"), ErrorHeader("k2", "v2"))
+	require.Equal(t, map[string]string{"k1": "v1", "k2": "v2"}, GetErrorHeaders(err))
+
+	f := NewFunction(nil, func(ctx context.Context, req interface{}) (interface{}, error) {
+		return nil, errors.Errorf("test error", errors.HTTPStatusUnauthorized, ErrorHeader("WWW-Authenticate", "Bearer"))
+	})
+
+	out, err := f.Handler(context.Background(), events.APIGatewayProxyRequest{})
+	require.NoError(t, err)
+	require.Equal(t, http.StatusUnauthorized, out.StatusCode)
+	require.Equal(t, "Bearer", out.Headers["WWW-Authenticate"])
+	require.Equal(t, "application/json; charset=utf-8", out.Headers["Content-Type"])
+}
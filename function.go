@@ -2,8 +2,12 @@ package mbd
 
 import (
 	"context"
+	"io"
 	"net/http"
 	"reflect"
+	"strings"
+	"sync/atomic"
+	"time"
 
 	"github.com/aws/aws-lambda-go/events"
 	"github.com/aws/aws-lambda-go/lambda"
@@ -17,20 +21,40 @@ type Checker func(ctx context.Context, in *events.APIGatewayProxyRequest, req in
 // Handler implements a Lambda function handler.
 type Handler func(ctx context.Context, req interface{}) (resp interface{}, err error)
 
+// Middleware wraps a Handler, allowing to observe or alter its request, response and error.
+type Middleware func(next Handler) Handler
+
 // RequestParser describes a custom request parser. The default is JSON.
 type RequestParser func(context.Context, reflect.Type, *events.APIGatewayProxyRequest) (interface{}, error)
 
 // Function sets up a Lambda function handler.
 type Function struct {
-	reqType   reflect.Type
-	reqParser RequestParser
-	handler   Handler
-	debug     Debug
-	providers []Provider
-	checkers  []Checker
+	reqType            reflect.Type
+	reqParser          RequestParser
+	handler            Handler
+	debug              Debug
+	providers          []Provider
+	checkers           []Checker
+	middlewares        []Middleware
+	encoders           []ResponseEncoder
+	errRenderer        ErrorRenderer
+	errMappers         []ErrorMapper
+	errReporting       *errorReporting
+	logger             Logger
+	metricsNamespace   string
+	metricsWriter      io.Writer
+	invoked            int32
+	spanExporter       SpanExporter
+	deadlineMargin     time.Duration
+	cors               *CORSConfig
+	cachePolicy        *CachePolicy
+	compression        bool
+	compressionMinSize int
+	decompressionLimit int64
 }
 
-// NewFunction initializes a new Function.
+// NewFunction initializes a new Function. Parsed requests are validated against the "validation" struct tags of
+// reqTemplate, e.g. `validation:"required,max=10"`, failing with 422 and the list of ValidationError(s).
 func NewFunction(reqTemplate interface{}, handler Handler) *Function {
 	reqType := noRequestBody
 	if reqTemplate != nil {
@@ -40,12 +64,25 @@ func NewFunction(reqTemplate interface{}, handler Handler) *Function {
 	errors.Assert(handler != nil, "handler must not be nil")
 	errors.Assert(reqType.Kind() == reflect.Struct, "reqTemplate must be nil or struct value")
 
+	err := checkValidationRules(reqType)
+	errors.Assert(err == nil, "reqTemplate has invalid validation rules: %v", err)
+
+	err = checkFileFields(reqType)
+	errors.Assert(err == nil, "reqTemplate has invalid file fields: %v", err)
+
 	return &Function{
-		reqType:   reqType,
-		reqParser: JSONRequestParser(),
-		handler:   handler,
-		debug:     false,
-		providers: make([]Provider, 0),
+		reqType:            reqType,
+		reqParser:          JSONRequestParser(),
+		handler:            handler,
+		debug:              false,
+		providers:          make([]Provider, 0),
+		checkers:           make([]Checker, 0),
+		middlewares:        make([]Middleware, 0),
+		encoders:           []ResponseEncoder{JSONResponseEncoder()},
+		errRenderer:        DefaultErrorRenderer(),
+		errMappers:         make([]ErrorMapper, 0),
+		deadlineMargin:     500 * time.Millisecond,
+		decompressionLimit: 10 << 20,
 	}
 }
 
@@ -61,6 +98,118 @@ func (e *Function) SetRequestParser(reqParser RequestParser) *Function {
 	return e
 }
 
+// SetResponseEncoders sets the ResponseEncoder(s) used for success and error responses. The encoder is selected based on
+// the request Accept header, the first one is used if the header is missing. Requests that do not accept any of the
+// given encoders are rejected with 406. If more than one encoder is set, or the ErrorRenderer has its own media type (see
+// ProblemErrorRenderer), responses include a "Vary: Accept" header. Default is JSON only.
+func (e *Function) SetResponseEncoders(encoders ...ResponseEncoder) *Function {
+	errors.Assert(len(encoders) > 0, "encoders must not be empty")
+	e.encoders = encoders
+	return e
+}
+
+// SetErrorRenderer sets a custom ErrorRenderer, e.g. ProblemErrorRenderer(). Default is DefaultErrorRenderer().
+func (e *Function) SetErrorRenderer(errRenderer ErrorRenderer) *Function {
+	e.errRenderer = errRenderer
+	return e
+}
+
+// AddErrorMappers adds one or more ErrorMapper(s) to the Function, e.g. StandardErrorMappers(). They are tried in order
+// on errors that do not carry an HTTP status, before rendering them.
+func (e *Function) AddErrorMappers(errMappers ...ErrorMapper) *Function {
+	e.errMappers = append(e.errMappers, errMappers...)
+	return e
+}
+
+// SetErrorReporter sets an ErrorReporter, e.g. NewJSONErrorReporter(os.Stderr). It is invoked for errors whose HTTP
+// status is in one of the given status classes (e.g. 5 for 5xx), and for all panics. If no status classes are given,
+// only 5xx errors are reported. Default is no ErrorReporter.
+func (e *Function) SetErrorReporter(errReporter ErrorReporter, statusClasses ...int) *Function {
+	if errReporter == nil {
+		e.errReporting = nil
+		return e
+	}
+
+	if len(statusClasses) == 0 {
+		statusClasses = []int{5}
+	}
+
+	e.errReporting = &errorReporting{
+		errReporter:   errReporter,
+		statusClasses: statusClasses,
+	}
+	return e
+}
+
+// SetLogger sets a Logger, e.g. NewJSONLogger(os.Stdout). It enables access logging and is made available to handlers
+// as a request-scoped Logger, see GetLogger(). Default is no Logger.
+func (e *Function) SetLogger(logger Logger) *Function {
+	e.logger = logger
+	return e
+}
+
+// SetMetrics enables emitting metrics in CloudWatch Embedded Metric Format to w (usually os.Stdout) at the end of each
+// invocation, under the given namespace. Built-in metrics are Latency, 2xx, 4xx, 5xx, ColdStart and Panic, with the
+// Resource and Method dimensions. Handlers can add their own metrics and dimensions, see GetMetrics(). A nil w disables
+// metrics. Default is disabled.
+func (e *Function) SetMetrics(namespace string, w io.Writer) *Function {
+	e.metricsNamespace = namespace
+	e.metricsWriter = w
+	return e
+}
+
+// SetTracing enables tracing, exporting spans to the given SpanExporter. The trace propagated by the "traceparent" or
+// "X-Amzn-Trace-Id" request headers is continued, if any. A span is created for the request and for the provider,
+// parsing, checker and handler phases; the handler span is made available to handlers, see GetSpan() and StartSpan().
+// A nil exporter disables tracing. Default is disabled.
+func (e *Function) SetTracing(exporter SpanExporter) *Function {
+	e.spanExporter = exporter
+	return e
+}
+
+// SetDeadlineMargin sets the safety margin subtracted from the Lambda deadline. When the resulting deadline expires, the
+// handler context is canceled and a 504 "timeout" error response is returned, while the handler is abandoned: errors it
+// returns afterwards are not reported, logged or traced. A negative margin disables the behavior. Default is 500ms.
+func (e *Function) SetDeadlineMargin(margin time.Duration) *Function {
+	e.deadlineMargin = margin
+	return e
+}
+
+// SetCORS enables Cross-Origin Resource Sharing with the given configuration. CORS headers are added to all success
+// and error responses, and preflight OPTIONS requests are answered without invoking the handler. A nil config disables
+// CORS. Default is disabled.
+func (e *Function) SetCORS(config *CORSConfig) *Function {
+	errors.Assert(config == nil || len(config.AllowedOrigins) > 0, "config.AllowedOrigins must not be empty")
+	errors.Assert(config == nil || !config.AllowCredentials || !containsString(config.AllowedOrigins, "*"),
+		`config.AllowedOrigins must not contain "*" if config.AllowCredentials is set`)
+	e.cors = config
+	return e
+}
+
+// SetCachePolicy sets the CachePolicy of success responses, e.g. PublicCachePolicy(). It can be overridden by returning
+// a *Response with a CachePolicy. A nil policy restores the default, which sends no-cache headers and no ETag.
+func (e *Function) SetCachePolicy(cachePolicy *CachePolicy) *Function {
+	e.cachePolicy = cachePolicy
+	return e
+}
+
+// SetCompression enables gzip/brotli compression of response bodies of at least minSize bytes, when allowed by the
+// request Accept-Encoding header, and decoding of gzip/brotli encoded request bodies. Compressed responses are base64
+// encoded, so API Gateway binary media types must be configured accordingly. Default is disabled.
+func (e *Function) SetCompression(enabled bool, minSize int) *Function {
+	e.compression = enabled
+	e.compressionMinSize = minSize
+	return e
+}
+
+// SetDecompressionLimit sets the maximum size of decoded gzip/brotli request bodies, larger ones are rejected with 413.
+// It guards against small compressed bodies that expand to exhaust memory. A non-positive limit disables the check.
+// Default is 10MiB.
+func (e *Function) SetDecompressionLimit(limit int64) *Function {
+	e.decompressionLimit = limit
+	return e
+}
+
 // AddProviders adds one or more Provider(s) to the Function.
 func (e *Function) AddProviders(providers ...Provider) *Function {
 	e.providers = append(e.providers, providers...)
@@ -73,41 +222,197 @@ func (e *Function) AddCheckers(checkers ...Checker) *Function {
 	return e
 }
 
+// Use adds one or more Middleware(s) to the Function. Middleware(s) wrap the Handler, after Provider(s), request
+// parsing and Checker(s) have run. The first added Middleware is the outermost.
+func (e *Function) Use(middlewares ...Middleware) *Function {
+	e.middlewares = append(e.middlewares, middlewares...)
+	return e
+}
+
 // Handler provides a handler function suitable for lambda.Start().
-func (e *Function) Handler(ctx context.Context, in events.APIGatewayProxyRequest) (out events.APIGatewayProxyResponse, _ error) {
-	ctx = populateContext(ctx, e.debug, &in)
+func (e *Function) Handler(ctx context.Context, in events.APIGatewayProxyRequest) (resp events.APIGatewayProxyResponse, _ error) {
+	start := time.Now()
+	ctx = e.populateContext(ctx, &in)
+
+	defer func() {
+		if err := errors.MaybeWrapRecover(recover()); err != nil {
+			resp = *adaptPanic(ctx, err)
+		}
+	}()
+
+	var out *events.APIGatewayProxyResponse
+	if e.cors != nil && isPreflightRequest(in.HTTPMethod, GetHeaders(ctx).multiGet) {
+		out = e.cors.newPreflightResponse(ctx)
+	} else {
+		out = e.handleWithDeadline(ctx, &in)
+	}
+
+	if e.compression {
+		if err := compressResponse(ctx, out, e.compressionMinSize); err != nil {
+			out = adaptError(ctx, err)
+		}
+	}
+
+	if e.cors != nil {
+		e.cors.decorate(ctx, out)
+	}
+
+	if _, ok := e.errRenderer.(mediaTypeErrorRenderer); ok || len(e.encoders) > 1 {
+		addVary(out, "Accept")
+	}
+
+	if e.logger != nil {
+		writeAccessLog(ctx, &in, out, start)
+	}
+
+	if span := GetSpan(ctx); span != nil {
+		span.SetAttribute("http.status_code", out.StatusCode)
+		span.End()
+	}
+
+	if metrics, ok := ctx.Value(metricsContextKey).(*Metrics); ok {
+		recordMetrics(metrics, out, time.Since(start), atomic.CompareAndSwapInt32(&e.invoked, 0, 1))
+		metrics.flush(e.metricsWriter, e.metricsNamespace)
+	}
+
+	return *out, nil
+}
+
+func (e *Function) populateContext(ctx context.Context, in *events.APIGatewayProxyRequest) context.Context {
+	ctx = populateContext(ctx, e.debug, in)
+	ctx = context.WithValue(ctx, errorRendererContextKey, e.errRenderer)
+	ctx = context.WithValue(ctx, errorMappersContextKey, e.errMappers)
+
+	if e.cachePolicy != nil {
+		ctx = context.WithValue(ctx, cachePolicyContextKey, e.cachePolicy)
+	}
+
+	if e.errReporting != nil {
+		ctx = context.WithValue(ctx, errorReportingContextKey, e.errReporting)
+	}
+
+	if e.logger != nil {
+		ctx = context.WithValue(ctx, loggerContextKey, newRequestLogger(e.logger, in))
+		ctx = context.WithValue(ctx, accessLogContextKey, &accessLog{})
+	}
+
+	if e.metricsWriter != nil {
+		ctx = context.WithValue(ctx, metricsContextKey, newMetrics().
+			AddDimension("Resource", in.Resource).
+			AddDimension("Method", in.HTTPMethod).
+			AddCount(PanicMetric, 0))
+	}
+
+	if e.spanExporter != nil {
+		ctx = context.WithValue(ctx, spanContextKey, startRootSpan(ctx, in.HTTPMethod+" "+in.Resource, e.spanExporter))
+	}
 
+	return ctx
+}
+
+func (e *Function) handleWithDeadline(ctx context.Context, in *events.APIGatewayProxyRequest) *events.APIGatewayProxyResponse {
+	ctx, err := e.negotiateResponseEncoder(ctx)
+	if err != nil {
+		return adaptError(ctx, err)
+	}
+
+	deadline, ok := ctx.Deadline()
+	if !ok || e.deadlineMargin < 0 {
+		return e.handle(ctx, in)
+	}
+
+	ctx, cancel := context.WithDeadline(ctx, deadline.Add(-e.deadlineMargin))
+	defer cancel()
+
+	state := deadlinePending
+	resultCh := make(chan *deadlineResult, 1)
+	go func() {
+		result := &deadlineResult{}
+		defer func() {
+			result.recovered = recover()
+			resultCh <- result
+		}()
+		result.out = e.handle(context.WithValue(ctx, deadlineStateContextKey, &state), in)
+	}()
+
+	select {
+	case result := <-resultCh:
+		return result.get()
+	case <-ctx.Done():
+		if !atomic.CompareAndSwapInt32(&state, deadlinePending, deadlineExpired) {
+			// the handler is already rendering an error response, which is reported instead of the timeout
+			return (<-resultCh).get()
+		}
+		return adaptError(ctx, errors.Errorf("handler did not complete before deadline", timeout))
+	}
+}
+
+func (e *Function) negotiateResponseEncoder(ctx context.Context) (context.Context, error) {
+	accept := strings.Join(GetHeaders(ctx).GetMulti("Accept"), ",")
+	encoder := negotiateResponseEncoder(accept, e.encoders)
+	if encoder == nil && acceptsErrorMediaType(accept, e.errRenderer) {
+		encoder = e.encoders[0]
+	}
+	if encoder == nil {
+		ctx = context.WithValue(ctx, responseEncoderContextKey, e.encoders[0])
+		return ctx, errors.Errorf("no acceptable response encoder", notAcceptable)
+	}
+	return context.WithValue(ctx, responseEncoderContextKey, encoder), nil
+}
+
+func (e *Function) handle(ctx context.Context, in *events.APIGatewayProxyRequest) (out *events.APIGatewayProxyResponse) {
 	defer func() {
 		if err := errors.MaybeWrapRecover(recover()); err != nil {
-			out = *adaptError(ctx, err)
+			out = adaptPanic(ctx, err)
 		}
 	}()
 
+	if e.compression {
+		if err := decompressRequest(ctx, in, e.decompressionLimit); err != nil {
+			return adaptError(ctx, err)
+		}
+	}
+
+	span := startChildSpan(ctx, "providers")
 	for _, provider := range e.providers {
 		ctx = provider(ctx)
 	}
+	span.End()
 
-	req, err := e.reqParser(ctx, e.reqType, &in)
-	if err != nil {
-		return *adaptError(ctx, err), nil
+	span = startChildSpan(ctx, "parse")
+	req, err := e.reqParser(ctx, e.reqType, in)
+	if err == nil {
+		err = validateRequest(req)
+	}
+	if endSpan(ctx, span, err); err != nil {
+		return adaptError(ctx, err)
 	}
 
+	span = startChildSpan(ctx, "checkers")
 	for _, checker := range e.checkers {
-		newCtx, err := checker(ctx, &in, req)
+		newCtx, err := checker(ctx, in, req)
 		if err != nil {
-			return *adaptError(ctx, err), nil
+			endSpan(ctx, span, err)
+			return adaptError(ctx, err)
 		}
 		if newCtx != nil {
 			ctx = newCtx
 		}
 	}
+	span.End()
 
-	resp, err := e.handler(ctx, req)
-	if err != nil {
-		return *adaptError(ctx, err), nil
+	handler := e.handler
+	for i := len(e.middlewares) - 1; i >= 0; i-- {
+		handler = e.middlewares[i](handler)
+	}
+
+	handlerCtx, span := StartSpan(ctx, "handler")
+	resp, err := handler(handlerCtx, req)
+	if endSpan(ctx, span, err); err != nil {
+		return adaptError(ctx, err)
 	}
 
-	return *adaptResponse(ctx, http.StatusOK, resp), nil
+	return adaptResponse(ctx, http.StatusOK, resp)
 }
 
 // Start invokes lambda.Start() passing the Function handler as argument.
@@ -0,0 +1,211 @@
+package mbd
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/ibrt/errors"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFunction_Deadline(t *testing.T) {
+	abandoned := make(chan error, 1)
+
+	f := NewFunction(nil, func(ctx context.Context, req interface{}) (interface{}, error) {
+		<-ctx.Done()
+		time.Sleep(10 * time.Millisecond)
+		abandoned <- ctx.Err()
+		return nil, nil
+	}).SetDeadlineMargin(50 * time.Millisecond)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	out, err := f.Handler(ctx, events.APIGatewayProxyRequest{
+		RequestContext: events.APIGatewayProxyRequestContext{RequestID: "test"},
+	})
+	require.NoError(t, err)
+	require.True(t, time.Since(start) < 100*time.Millisecond)
+	require.Equal(t, http.StatusGatewayTimeout, out.StatusCode)
+	require.JSONEq(t, `{"statusCode":504,"publicMessage":"timeout","requestId":"test"}`, out.Body)
+	require.Equal(t, context.DeadlineExceeded, <-abandoned)
+}
+
+func TestFunction_Deadline_Observability(t *testing.T) {
+	rendered := make(chan struct{}, 2)
+	logBuf := &bytes.Buffer{}
+	exporter := NewMemorySpanExporter()
+	reporter := NewMemoryErrorReporter()
+
+	f := NewFunction(nil, func(ctx context.Context, req interface{}) (interface{}, error) {
+		<-ctx.Done()
+		time.Sleep(10 * time.Millisecond)
+		return nil, errors.Errorf("late error")
+	}).
+		SetDeadlineMargin(50 * time.Millisecond).
+		SetLogger(NewJSONLogger(logBuf)).
+		SetTracing(exporter).
+		SetErrorReporter(reporter).
+		SetErrorRenderer(ErrorRendererFunc(func(ctx context.Context, err error, errs []error) *events.APIGatewayProxyResponse {
+			defer func() { rendered <- struct{}{} }()
+			return DefaultErrorRenderer().RenderError(ctx, err, errs)
+		}))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	out, err := f.Handler(ctx, events.APIGatewayProxyRequest{})
+	require.NoError(t, err)
+	require.Equal(t, http.StatusGatewayTimeout, out.StatusCode)
+
+	<-rendered
+	<-rendered // the abandoned handler error
+
+	reports := reporter.GetReports()
+	require.Len(t, reports, 1)
+	require.Equal(t, http.StatusGatewayTimeout, reports[0].StatusCode)
+
+	var root, handler *Span
+	for _, span := range exporter.GetSpans() {
+		if span.ParentSpanID == "" {
+			root = span
+		}
+		if span.Name == "handler" {
+			handler = span
+		}
+	}
+	require.NotNil(t, root)
+	require.Equal(t, "handler did not complete before deadline", root.Error)
+	require.NotNil(t, handler)
+	require.Empty(t, handler.Error)
+
+	lines := strings.Split(strings.TrimSpace(logBuf.String()), "\n")
+	require.Len(t, lines, 1)
+	require.Contains(t, lines[0], `"publicMessage":"timeout"`)
+}
+
+func TestFunction_Deadline_Encoder(t *testing.T) {
+	f := NewFunction(nil, func(ctx context.Context, req interface{}) (interface{}, error) {
+		<-ctx.Done()
+		return nil, nil
+	}).
+		SetDeadlineMargin(50 * time.Millisecond).
+		SetResponseEncoders(XMLResponseEncoder())
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	out, err := f.Handler(ctx, events.APIGatewayProxyRequest{})
+	require.NoError(t, err)
+	require.Equal(t, http.StatusGatewayTimeout, out.StatusCode)
+	require.Equal(t, "application/xml; charset=utf-8", out.Headers["Content-Type"])
+}
+
+func TestFunction_Deadline_RendererPanic(t *testing.T) {
+	f := NewFunction(nil, func(ctx context.Context, req interface{}) (interface{}, error) {
+		return nil, errors.Errorf("handler error")
+	}).
+		SetDeadlineMargin(50 * time.Millisecond).
+		SetErrorRenderer(ErrorRendererFunc(func(ctx context.Context, err error, errs []error) *events.APIGatewayProxyResponse {
+			panic("renderer panic")
+		}))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	require.PanicsWithValue(t, "renderer panic", func() {
+		_, _ = f.Handler(ctx, events.APIGatewayProxyRequest{})
+	})
+}
+
+func TestFunction_Deadline_AbandonedRendererPanic(t *testing.T) {
+	rendered := make(chan struct{})
+
+	f := NewFunction(nil, func(ctx context.Context, req interface{}) (interface{}, error) {
+		<-ctx.Done()
+		time.Sleep(10 * time.Millisecond)
+		return nil, errors.Errorf("late error")
+	}).
+		SetDeadlineMargin(50 * time.Millisecond).
+		SetErrorRenderer(ErrorRendererFunc(func(ctx context.Context, err error, errs []error) *events.APIGatewayProxyResponse {
+			if errors.GetHTTPStatusOrDefault(err, http.StatusInternalServerError) != http.StatusGatewayTimeout {
+				defer close(rendered)
+				panic("renderer panic")
+			}
+			return DefaultErrorRenderer().RenderError(ctx, err, errs)
+		}))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	out, err := f.Handler(ctx, events.APIGatewayProxyRequest{})
+	require.NoError(t, err)
+	require.Equal(t, http.StatusGatewayTimeout, out.StatusCode)
+
+	<-rendered
+	time.Sleep(10 * time.Millisecond) // the recovered panic is discarded, instead of crashing the process
+}
+
+func TestFunction_Deadline_Completed(t *testing.T) {
+	f := NewFunction(nil, func(ctx context.Context, req interface{}) (interface{}, error) {
+		_, ok := ctx.Deadline()
+		return map[string]bool{"deadline": ok}, nil
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	out, err := f.Handler(ctx, events.APIGatewayProxyRequest{})
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, out.StatusCode)
+	require.JSONEq(t, `{"deadline":true}`, out.Body)
+
+	f.SetDeadlineMargin(-1)
+	ctx, cancel = context.WithTimeout(context.Background(), time.Millisecond)
+	defer cancel()
+	time.Sleep(2 * time.Millisecond)
+
+	out, err = f.Handler(ctx, events.APIGatewayProxyRequest{})
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, out.StatusCode)
+}
+
+func TestFunction_NilErrorRendererHeaders(t *testing.T) {
+	f := NewFunction(nil, func(ctx context.Context, req interface{}) (interface{}, error) {
+		return nil, errors.Errorf("test error")
+	}).SetErrorRenderer(ErrorRendererFunc(func(ctx context.Context, err error, errs []error) *events.APIGatewayProxyResponse {
+		return &events.APIGatewayProxyResponse{StatusCode: http.StatusInternalServerError, Body: strings.Repeat("a", 100)}
+	})).
+		SetResponseEncoders(JSONResponseEncoder(), XMLResponseEncoder()).
+		SetCompression(true, 10).
+		SetCORS(&CORSConfig{AllowedOrigins: []string{"*"}})
+
+	out, err := f.Handler(context.Background(), events.APIGatewayProxyRequest{
+		Headers: map[string]string{"Origin": "https://example.com"},
+	})
+	require.NoError(t, err)
+	require.Equal(t, http.StatusInternalServerError, out.StatusCode)
+	require.Equal(t, "Accept-Encoding, Origin, Accept", out.Headers["Vary"])
+	require.Equal(t, "*", out.Headers["Access-Control-Allow-Origin"])
+}
+
+func TestFunction_PostProcessingPanic(t *testing.T) {
+	f := NewFunction(nil, func(ctx context.Context, req interface{}) (interface{}, error) {
+		return nil, nil
+	}).SetTracing(SpanExporterFunc(func(span *Span) {
+		if span.ParentSpanID == "" { // root span, exported after the response is built
+			panic("test panic")
+		}
+	}))
+
+	out, err := f.Handler(context.Background(), events.APIGatewayProxyRequest{})
+	require.NoError(t, err)
+	require.Equal(t, http.StatusInternalServerError, out.StatusCode)
+	require.JSONEq(t, `{"statusCode":500,"publicMessage":"internal-server-error","requestId":""}`, out.Body)
+}
@@ -1,10 +1,17 @@
 module github.com/ibrt/mbd
 
+go 1.18
+
 require (
-	github.com/aws/aws-lambda-go v1.10.0
+	github.com/andybalholm/brotli v1.1.1
+	github.com/aws/aws-lambda-go v1.47.0
 	github.com/davecgh/go-spew v1.1.1
 	github.com/gorilla/schema v1.1.0
 	github.com/ibrt/errors v1.3.0
-	github.com/stretchr/objx v0.2.0 // indirect
-	github.com/stretchr/testify v1.3.0
+	github.com/stretchr/testify v1.7.2
+)
+
+require (
+	github.com/pmezard/go-difflib v1.0.0 // indirect
+	gopkg.in/yaml.v3 v3.0.1 // indirect
 )
@@ -0,0 +1,205 @@
+package mbd
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-lambda-go/lambda"
+)
+
+// HandlerV2 provides a handler function suitable for lambda.Start(), for API Gateway HTTP APIs using the payload format
+// version 2.0. The request is converted to the REST API format, so that handlers, parsers, checkers and getters work
+// unchanged on either API type.
+func (e *Function) HandlerV2(ctx context.Context, in events.APIGatewayV2HTTPRequest) (events.APIGatewayV2HTTPResponse, error) {
+	out, err := e.Handler(ctx, *newProxyRequestFromV2(&in))
+	return newV2Response(&out), err
+}
+
+// StartV2 invokes lambda.Start() passing the Function HandlerV2 as argument.
+func (e *Function) StartV2() {
+	lambda.Start(e.HandlerV2)
+}
+
+// HandlerV2 provides a handler function suitable for lambda.Start(), for API Gateway HTTP APIs using the payload format
+// version 2.0. See Function.HandlerV2 for details.
+func (r *Router) HandlerV2(ctx context.Context, in events.APIGatewayV2HTTPRequest) (events.APIGatewayV2HTTPResponse, error) {
+	out, err := r.Handler(ctx, *newProxyRequestFromV2(&in))
+	return newV2Response(&out), err
+}
+
+// StartV2 invokes lambda.Start() passing the Router HandlerV2 as argument.
+func (r *Router) StartV2() {
+	lambda.Start(r.HandlerV2)
+}
+
+// newProxyRequestFromV2 converts an HTTP API request to a REST API request:
+//   - the resource is taken from the route key (e.g. "GET /users/{id}"), "$default" is kept as is
+//   - the path is the raw path, without the stage prefix added for named stages
+//   - headers are kept comma-joined, like single REST API headers, and cookies are joined into the Cookie header
+//   - the query string is parsed from the raw query string, to preserve repeated parameters
+//   - JWT authorizer claims and scopes are exposed as the "claims" and "scopes" authorizer values, like Cognito
+//     authorizers, and Lambda authorizer context values are exposed as authorizer values, like custom authorizers
+func newProxyRequestFromV2(in *events.APIGatewayV2HTTPRequest) *events.APIGatewayProxyRequest {
+	resource := in.RouteKey
+	if i := strings.Index(resource, " "); i >= 0 {
+		resource = resource[i+1:]
+	}
+
+	path := in.RawPath
+	if stage := "/" + in.RequestContext.Stage; stage != "/" && stage != "/$default" && (path == stage || strings.HasPrefix(path, stage+"/")) {
+		path = strings.TrimPrefix(path, stage)
+	}
+	if path == "" {
+		path = "/"
+	}
+
+	headers := make(map[string]string, len(in.Headers)+1)
+	for k, v := range in.Headers {
+		headers[k] = v
+	}
+	if len(in.Cookies) > 0 {
+		headers["cookie"] = strings.Join(in.Cookies, "; ")
+	}
+
+	multiHeaders := make(map[string][]string, len(headers))
+	for k, v := range headers {
+		multiHeaders[k] = []string{v}
+	}
+
+	queryString, multiQueryString := newV2QueryString(in)
+
+	return &events.APIGatewayProxyRequest{
+		Resource:                        resource,
+		Path:                            path,
+		HTTPMethod:                      in.RequestContext.HTTP.Method,
+		Headers:                         headers,
+		MultiValueHeaders:               multiHeaders,
+		QueryStringParameters:           queryString,
+		MultiValueQueryStringParameters: multiQueryString,
+		PathParameters:                  in.PathParameters,
+		StageVariables:                  in.StageVariables,
+		RequestContext: events.APIGatewayProxyRequestContext{
+			AccountID:        in.RequestContext.AccountID,
+			Stage:            in.RequestContext.Stage,
+			DomainName:       in.RequestContext.DomainName,
+			DomainPrefix:     in.RequestContext.DomainPrefix,
+			RequestID:        in.RequestContext.RequestID,
+			Protocol:         in.RequestContext.HTTP.Protocol,
+			Identity:         newV2Identity(in),
+			ResourcePath:     resource,
+			Path:             in.RawPath,
+			Authorizer:       newV2Authorizer(in.RequestContext.Authorizer),
+			HTTPMethod:       in.RequestContext.HTTP.Method,
+			RequestTime:      in.RequestContext.Time,
+			RequestTimeEpoch: in.RequestContext.TimeEpoch,
+			APIID:            in.RequestContext.APIID,
+		},
+		Body:            in.Body,
+		IsBase64Encoded: in.IsBase64Encoded,
+	}
+}
+
+// newV2QueryString returns the single and multi value query string parameters. The raw query string is preferred, as
+// the parsed parameters join repeated values with commas.
+func newV2QueryString(in *events.APIGatewayV2HTTPRequest) (map[string]string, map[string][]string) {
+	multi := make(map[string][]string)
+
+	if in.RawQueryString != "" {
+		values, _ := url.ParseQuery(in.RawQueryString) // keep the parameters parsed before any error
+		for k, v := range values {
+			multi[k] = v
+		}
+	} else {
+		for k, v := range in.QueryStringParameters {
+			multi[k] = strings.Split(v, ",")
+		}
+	}
+
+	single := make(map[string]string, len(multi))
+	for k, v := range multi {
+		single[k] = v[len(v)-1]
+	}
+
+	return single, multi
+}
+
+func newV2Identity(in *events.APIGatewayV2HTTPRequest) events.APIGatewayRequestIdentity {
+	identity := events.APIGatewayRequestIdentity{
+		SourceIP:  in.RequestContext.HTTP.SourceIP,
+		UserAgent: in.RequestContext.HTTP.UserAgent,
+	}
+
+	if authorizer := in.RequestContext.Authorizer; authorizer != nil && authorizer.IAM != nil {
+		identity.AccessKey = authorizer.IAM.AccessKey
+		identity.AccountID = authorizer.IAM.AccountID
+		identity.Caller = authorizer.IAM.CallerID
+		identity.User = authorizer.IAM.UserID
+		identity.UserArn = authorizer.IAM.UserARN
+	}
+
+	return identity
+}
+
+func newV2Authorizer(authorizer *events.APIGatewayV2HTTPRequestContextAuthorizerDescription) map[string]interface{} {
+	if authorizer == nil {
+		return nil
+	}
+
+	values := make(map[string]interface{})
+
+	for k, v := range authorizer.Lambda {
+		values[k] = v
+	}
+
+	if authorizer.JWT != nil {
+		claims := make(map[string]interface{}, len(authorizer.JWT.Claims))
+		for k, v := range authorizer.JWT.Claims {
+			claims[k] = v
+		}
+		values["claims"] = claims
+
+		if len(authorizer.JWT.Scopes) > 0 {
+			values["scopes"] = authorizer.JWT.Scopes
+		}
+	}
+
+	return values
+}
+
+// newV2Response converts a REST API response to an HTTP API response. HTTP APIs do not support multi-value headers:
+// they are joined with commas, except for Set-Cookie headers, which are moved to Cookies. As with REST APIs, multi-value
+// headers take precedence over single-value headers with the same key.
+func newV2Response(out *events.APIGatewayProxyResponse) events.APIGatewayV2HTTPResponse {
+	resp := events.APIGatewayV2HTTPResponse{
+		StatusCode:      out.StatusCode,
+		Headers:         make(map[string]string, len(out.Headers)+len(out.MultiValueHeaders)),
+		Body:            out.Body,
+		IsBase64Encoded: out.IsBase64Encoded,
+	}
+
+	for k, v := range out.Headers {
+		if _, ok := out.MultiValueHeaders[k]; ok {
+			continue
+		}
+		if http.CanonicalHeaderKey(k) == "Set-Cookie" {
+			resp.Cookies = append(resp.Cookies, v)
+			continue
+		}
+		resp.Headers[k] = v
+	}
+
+	for k, v := range out.MultiValueHeaders {
+		if http.CanonicalHeaderKey(k) == "Set-Cookie" {
+			resp.Cookies = append(resp.Cookies, v...)
+			continue
+		}
+		if len(v) > 0 {
+			resp.Headers[k] = strings.Join(v, ", ")
+		}
+	}
+
+	return resp
+}
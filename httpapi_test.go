@@ -0,0 +1,160 @@
+package mbd
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFunction_HandlerV2(t *testing.T) {
+	f := NewFunction(nil, func(ctx context.Context, _ interface{}) (interface{}, error) {
+		require.Equal(t, &Path{Resource: "/users/{id}", Path: "/users/1", Method: "GET"}, GetPath(ctx))
+		require.Equal(t, "1", GetPathParameters(ctx).Get("id"))
+		require.Equal(t, []string{"a", "b"}, GetQueryString(ctx).GetMulti("q"))
+		require.Equal(t, "b", GetQueryString(ctx).Get("q"))
+		require.Equal(t, "text/html, application/json", GetHeaders(ctx).Get("Accept"))
+		require.Equal(t, "a=1; b=2", GetHeaders(ctx).Get("Cookie"))
+		require.Equal(t, "v", GetStageVariables(ctx).Get("k"))
+		require.Equal(t, "request-id", GetRequestContext(ctx).RequestID)
+		require.Equal(t, "127.0.0.1", GetRequestContext(ctx).Identity.SourceIP)
+		require.Equal(t, &CognitoClaims{
+			Subject:  "subject",
+			Username: "user",
+			Groups:   []string{"admin", "users"},
+			Scopes:   []string{"read", "write"},
+			Raw:      map[string]interface{}{"sub": "subject", "username": "user", "cognito:groups": "[admin users]"},
+		}, GetCognitoClaims(ctx))
+
+		return NewResponse(http.StatusCreated, map[string]string{"k": "v"}).
+			SetHeader("X-Single", "v").
+			AddHeader("X-Multi", "a").
+			AddHeader("X-Multi", "b").
+			AddCookie(&http.Cookie{Name: "c", Value: "1"}).
+			AddCookie(&http.Cookie{Name: "d", Value: "2"}), nil
+	}).AddCheckers(RequireScopes("write"), RequireGroups("admin"))
+
+	out, err := f.HandlerV2(context.Background(), events.APIGatewayV2HTTPRequest{
+		Version:        "2.0",
+		RouteKey:       "GET /users/{id}",
+		RawPath:        "/prod/users/1",
+		RawQueryString: "q=a&q=b",
+		Cookies:        []string{"a=1", "b=2"},
+		Headers: map[string]string{
+			"accept": "text/html, application/json",
+		},
+		QueryStringParameters: map[string]string{"q": "a,b"},
+		PathParameters:        map[string]string{"id": "1"},
+		StageVariables:        map[string]string{"k": "v"},
+		RequestContext: events.APIGatewayV2HTTPRequestContext{
+			RouteKey:  "GET /users/{id}",
+			Stage:     "prod",
+			RequestID: "request-id",
+			HTTP: events.APIGatewayV2HTTPRequestContextHTTPDescription{
+				Method:   "GET",
+				Path:     "/prod/users/1",
+				SourceIP: "127.0.0.1",
+			},
+			Authorizer: &events.APIGatewayV2HTTPRequestContextAuthorizerDescription{
+				JWT: &events.APIGatewayV2HTTPRequestContextAuthorizerJWTDescription{
+					Claims: map[string]string{"sub": "subject", "username": "user", "cognito:groups": "[admin users]"},
+					Scopes: []string{"read", "write"},
+				},
+			},
+		},
+	})
+	require.NoError(t, err)
+	require.Equal(t, http.StatusCreated, out.StatusCode)
+	require.JSONEq(t, `{"k":"v"}`, out.Body)
+	require.Equal(t, "v", out.Headers["X-Single"])
+	require.Equal(t, "a, b", out.Headers["X-Multi"])
+	require.Equal(t, "application/json; charset=utf-8", out.Headers["Content-Type"])
+	require.Equal(t, []string{"c=1", "d=2"}, out.Cookies)
+	require.Nil(t, out.MultiValueHeaders)
+
+	out, err = f.HandlerV2(context.Background(), events.APIGatewayV2HTTPRequest{
+		Version:  "2.0",
+		RouteKey: "GET /users/{id}",
+		RawPath:  "/users/1",
+		RequestContext: events.APIGatewayV2HTTPRequestContext{
+			Stage: "$default",
+			HTTP:  events.APIGatewayV2HTTPRequestContextHTTPDescription{Method: "GET"},
+		},
+	})
+	require.NoError(t, err)
+	require.Equal(t, http.StatusForbidden, out.StatusCode)
+}
+
+func TestRouter_HandlerV2(t *testing.T) {
+	r := newRouterTestRouter()
+
+	out, err := r.HandlerV2(context.Background(), events.APIGatewayV2HTTPRequest{
+		Version:        "2.0",
+		RouteKey:       "$default",
+		RawPath:        "/files/a/b",
+		RequestContext: events.APIGatewayV2HTTPRequestContext{HTTP: events.APIGatewayV2HTTPRequestContextHTTPDescription{Method: "PUT"}},
+	})
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, out.StatusCode)
+	require.Equal(t, map[string]interface{}{
+		"name":           "files",
+		"resource":       "/files/{path+}",
+		"pathParameters": map[string]interface{}{"path": "a/b"},
+	}, parseRouterTestResponseV2(t, out))
+
+	out, err = r.HandlerV2(context.Background(), events.APIGatewayV2HTTPRequest{
+		Version:        "2.0",
+		RouteKey:       "GET /users/{id}",
+		RawPath:        "/users/1",
+		PathParameters: map[string]string{"id": "1"},
+		RequestContext: events.APIGatewayV2HTTPRequestContext{HTTP: events.APIGatewayV2HTTPRequestContextHTTPDescription{Method: "GET"}},
+	})
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, out.StatusCode)
+	require.Equal(t, "getUser", parseRouterTestResponseV2(t, out)["name"])
+
+	out, err = r.HandlerV2(context.Background(), events.APIGatewayV2HTTPRequest{
+		Version:        "2.0",
+		RouteKey:       "$default",
+		RawPath:        "/users",
+		RequestContext: events.APIGatewayV2HTTPRequestContext{HTTP: events.APIGatewayV2HTTPRequestContextHTTPDescription{Method: "DELETE"}},
+	})
+	require.NoError(t, err)
+	require.Equal(t, http.StatusMethodNotAllowed, out.StatusCode)
+	require.Equal(t, "GET, POST", out.Headers["Allow"])
+}
+
+func TestNewProxyRequestFromV2(t *testing.T) {
+	in := newProxyRequestFromV2(&events.APIGatewayV2HTTPRequest{
+		RawPath:               "/prod",
+		QueryStringParameters: map[string]string{"q": "a,b"},
+		RequestContext: events.APIGatewayV2HTTPRequestContext{
+			Stage: "prod",
+			Authorizer: &events.APIGatewayV2HTTPRequestContextAuthorizerDescription{
+				Lambda: map[string]interface{}{"tenantId": "tenant"},
+				IAM:    &events.APIGatewayV2HTTPRequestContextAuthorizerIAMDescription{UserARN: "arn", AccountID: "account"},
+			},
+		},
+	})
+	require.Equal(t, "/", in.Path)
+	require.Equal(t, map[string][]string{"q": {"a", "b"}}, in.MultiValueQueryStringParameters)
+	require.Equal(t, map[string]interface{}{"tenantId": "tenant"}, in.RequestContext.Authorizer)
+	require.Equal(t, "arn", in.RequestContext.Identity.UserArn)
+	require.Equal(t, "account", in.RequestContext.Identity.AccountID)
+
+	in = newProxyRequestFromV2(&events.APIGatewayV2HTTPRequest{
+		RawPath:        "/production/users",
+		RequestContext: events.APIGatewayV2HTTPRequestContext{Stage: "prod"},
+	})
+	require.Equal(t, "/production/users", in.Path)
+	require.Nil(t, in.RequestContext.Authorizer)
+}
+
+func parseRouterTestResponseV2(t *testing.T, out events.APIGatewayV2HTTPResponse) map[string]interface{} {
+	resp := map[string]interface{}{}
+	require.NoError(t, json.Unmarshal([]byte(out.Body), &resp))
+	return resp
+}
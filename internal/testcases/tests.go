@@ -12,6 +12,12 @@ import (
 	"github.com/stretchr/testify/require"
 )
 
+type contextKey int
+
+const (
+	testMiddlewareContextKey contextKey = iota
+)
+
 // TestRequest is a request for test functions.
 type TestRequest struct {
 	Value string `json:"value" schema:"value"`
@@ -33,6 +39,7 @@ type TestCase struct {
 	Handler       mbd.Handler
 	Providers     []mbd.Provider
 	Checkers      []mbd.Checker
+	Middlewares   []mbd.Middleware
 	Assertion     func(require.TestingT, int, map[string][]string, interface{})
 }
 
@@ -333,5 +340,88 @@ var testCases = []*TestCase{
 			require.Equal(t, http.StatusOK, statusCode)
 			require.Equal(t, &TestResponse{Value: "testValue"}, response)
 		},
+	}, {
+		Name:          "CustomResponse",
+		ReqTemplate:   TestRequest{},
+		FormReqParser: false,
+		RespTemplate:  TestResponse{},
+		Request: &TestRequest{
+			Value: "testValue",
+		},
+		Handler: func(ctx context.Context, req interface{}) (interface{}, error) {
+			return mbd.NewResponse(http.StatusCreated, &TestResponse{Value: req.(*TestRequest).Value}).
+				AddHeader("Location", "/CustomResponse/testValue").
+				AddCookie(&http.Cookie{Name: "test", Value: "testValue"}), nil
+		},
+		Assertion: func(t require.TestingT, statusCode int, headers map[string][]string, resp interface{}) {
+			response := resp.(*TestResponse)
+
+			require.Equal(t, http.StatusCreated, statusCode)
+			require.Equal(t, []string{"/CustomResponse/testValue"}, headers["Location"])
+			require.Equal(t, []string{"test=testValue"}, headers["Set-Cookie"])
+			require.Equal(t, &TestResponse{Value: "testValue"}, response)
+		},
+	}, {
+		Name:          "Middleware",
+		ReqTemplate:   TestRequest{},
+		FormReqParser: false,
+		RespTemplate:  TestResponse{},
+		Request: &TestRequest{
+			Value: "testValue",
+		},
+		Handler: func(ctx context.Context, req interface{}) (interface{}, error) {
+			return &TestResponse{
+				Value: req.(*TestRequest).Value + "-" + ctx.Value(testMiddlewareContextKey).(string),
+			}, nil
+		},
+		Middlewares: []mbd.Middleware{
+			func(next mbd.Handler) mbd.Handler {
+				return func(ctx context.Context, req interface{}) (interface{}, error) {
+					resp, err := next(context.WithValue(ctx, testMiddlewareContextKey, "outer"), req)
+					resp.(*TestResponse).Value += "-outer"
+					return resp, err
+				}
+			},
+			func(next mbd.Handler) mbd.Handler {
+				return func(ctx context.Context, req interface{}) (interface{}, error) {
+					resp, err := next(context.WithValue(ctx, testMiddlewareContextKey, ctx.Value(testMiddlewareContextKey).(string)+"-inner"), req)
+					resp.(*TestResponse).Value += "-inner"
+					return resp, err
+				}
+			},
+		},
+		Assertion: func(t require.TestingT, statusCode int, headers map[string][]string, resp interface{}) {
+			response := resp.(*TestResponse)
+
+			require.Equal(t, http.StatusOK, statusCode)
+			require.Equal(t, &TestResponse{Value: "testValue-outer-inner-inner-outer"}, response)
+		},
+	}, {
+		Name:          "MiddlewarePanic",
+		ReqTemplate:   TestRequest{},
+		FormReqParser: false,
+		RespTemplate:  mbd.ErrorResponse{},
+		Request: &TestRequest{
+			Value: "testValue",
+		},
+		Handler: func(ctx context.Context, req interface{}) (interface{}, error) {
+			return nil, nil
+		},
+		Middlewares: []mbd.Middleware{
+			func(next mbd.Handler) mbd.Handler {
+				return func(ctx context.Context, req interface{}) (interface{}, error) {
+					panic(errors.Errorf("test error", errors.HTTPStatusConflict, errors.PublicMessage("test-error")))
+				}
+			},
+		},
+		Assertion: func(t require.TestingT, statusCode int, headers map[string][]string, resp interface{}) {
+			errorResponse := resp.(*mbd.ErrorResponse)
+
+			require.Equal(t, http.StatusConflict, statusCode)
+			require.Equal(t, http.StatusConflict, errorResponse.StatusCode)
+			require.Equal(t, "test-error", errorResponse.PublicMessage)
+			require.Len(t, errorResponse.Errors, 1)
+			require.Equal(t, "test error", errorResponse.Errors[0].Error)
+		},
 	},
 }
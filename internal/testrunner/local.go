@@ -32,7 +32,8 @@ func (r *localRunner) RunTest(t *testing.T, c *testcases.TestCase) {
 			return testcontext.WithTestingT(ctx, t)
 		}).
 		AddProviders(c.Providers...).
-		AddCheckers(c.Checkers...)
+		AddCheckers(c.Checkers...).
+		Use(c.Middlewares...)
 
 	if c.FormReqParser {
 		f.SetRequestParser(mbd.FormRequestParser())
@@ -76,7 +76,8 @@ func main() {
 		SetDebug({{if .DisableDebug }}false{{else}}true{{end}}).
 		AddProviders(testrunner.RemoteTestingTProvider).
 		AddProviders(testCase.Providers...).
-		AddCheckers(testCase.Checkers...)
+		AddCheckers(testCase.Checkers...).
+		Use(testCase.Middlewares...)
 
 	if testCase.FormReqParser {
 		f.SetRequestParser(mbd.FormRequestParser())
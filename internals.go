@@ -2,10 +2,11 @@ package mbd
 
 import (
 	"context"
-	"encoding/json"
+	"encoding/base64"
 	"net/http"
 	"reflect"
 	"strings"
+	"sync/atomic"
 
 	"github.com/aws/aws-lambda-go/events"
 	"github.com/ibrt/errors"
@@ -17,10 +18,13 @@ type noRequestBodyType struct {
 
 // ErrorResponse describes an error response.
 type ErrorResponse struct {
-	StatusCode    int                   `json:"statusCode"`
-	PublicMessage string                `json:"publicMessage"`
-	RequestID     string                `json:"requestId"`
-	Errors        []*ErrorResponseError `json:"errors,omitempty"` // included only if debug context value is set to true
+	StatusCode       int                   `json:"statusCode"`
+	PublicMessage    string                `json:"publicMessage"`
+	Code             string                `json:"code,omitempty"`
+	Details          ErrorDetails          `json:"details,omitempty"`
+	RequestID        string                `json:"requestId"`
+	ValidationErrors []*ValidationError    `json:"validationErrors,omitempty"`
+	Errors           []*ErrorResponseError `json:"errors,omitempty"` // included only if debug context value is set to true
 }
 
 // ErrorResponseError is an entry in the Errors section of ErrorResponse.
@@ -36,24 +40,108 @@ type SerializedResponse struct {
 	Body            string
 }
 
+// deadlineResult is the outcome of a handler run by handleWithDeadline. A panic that escaped the handler, e.g. raised by
+// the ErrorRenderer, is recovered so that it does not crash the process, and re-raised on the calling goroutine.
+type deadlineResult struct {
+	out       *events.APIGatewayProxyResponse
+	recovered interface{}
+}
+
+func (r *deadlineResult) get() *events.APIGatewayProxyResponse {
+	if r.recovered != nil {
+		panic(r.recovered)
+	}
+	return r.out
+}
+
+// Deadline states, see handleWithDeadline.
+const (
+	deadlinePending int32 = iota
+	deadlineHandled
+	deadlineExpired
+)
+
 var (
-	invalidBody        = errors.Behaviors(errors.HTTPStatusBadRequest, errors.PublicMessage("invalid-body"))
-	invalidContentType = errors.Behaviors(errors.HTTPStatusBadRequest, errors.PublicMessage("invalid-content-type"))
-	unexpectedBody     = errors.Behaviors(errors.HTTPStatusBadRequest, errors.PublicMessage("unexpected-body"))
-	noRequestBody      = reflect.TypeOf(noRequestBodyType{})
+	invalidBody            = errors.Behaviors(errors.HTTPStatusBadRequest, errors.PublicMessage("invalid-body"))
+	invalidContentType     = errors.Behaviors(errors.HTTPStatusUnsupportedMediaType, errors.PublicMessage("invalid-content-type"))
+	unexpectedBody         = errors.Behaviors(errors.HTTPStatusBadRequest, errors.PublicMessage("unexpected-body"))
+	invalidParameter       = errors.Behaviors(errors.HTTPStatusBadRequest, errors.PublicMessage("invalid-parameter"))
+	bodyTooLarge           = errors.Behaviors(errors.HTTPStatusRequestEntityTooLarge, errors.PublicMessage("body-too-large"))
+	routeNotFound          = errors.Behaviors(errors.HTTPStatusNotFound, errors.PublicMessage("not-found"))
+	methodNotAllowed       = errors.Behaviors(errors.HTTPStatusMethodNotAllowed, errors.PublicMessage("method-not-allowed"))
+	notAcceptable          = errors.Behaviors(errors.HTTPStatusNotAcceptable, errors.PublicMessage("not-acceptable"))
+	invalidContentEncoding = errors.Behaviors(errors.HTTPStatusUnsupportedMediaType, errors.PublicMessage("invalid-content-encoding"))
+	validationFailed       = errors.Behaviors(errors.HTTPStatusUnprocessableEntity, errors.PublicMessage("validation-failed"))
+	timeout                = errors.Behaviors(errors.HTTPStatus(http.StatusGatewayTimeout), errors.PublicMessage("timeout"))
+	noRequestBody          = reflect.TypeOf(noRequestBodyType{})
 )
 
+func getBody(in *events.APIGatewayProxyRequest) ([]byte, error) {
+	if !in.IsBase64Encoded {
+		return []byte(in.Body), nil
+	}
+
+	body, err := base64.StdEncoding.DecodeString(in.Body)
+	if err != nil {
+		return nil, errors.Wrap(err, errors.Prefix("invalid base64 Body"), invalidBody)
+	}
+
+	return body, nil
+}
+
 func adaptError(ctx context.Context, err error) *events.APIGatewayProxyResponse {
+	return renderError(ctx, err, false)
+}
+
+func adaptPanic(ctx context.Context, err error) *events.APIGatewayProxyResponse {
+	return renderError(ctx, err, true)
+}
+
+func renderError(ctx context.Context, err error, isPanic bool) *events.APIGatewayProxyResponse {
+	err = mapError(ctx, err)
+	errs := errors.Split(err)
+	statusCode := errors.GetHTTPStatusOrDefault(err, http.StatusInternalServerError)
+
+	if markHandledUnlessAbandoned(ctx) {
+		reportError(ctx, err, errs, isPanic)
+		setAccessLogPublicMessage(ctx, errors.GetPublicMessageOrDefault(err, getDefaultPublicMessage(statusCode)))
+
+		if isPanic {
+			recordPanic(ctx)
+		}
+
+		if span := GetSpan(ctx); span != nil {
+			span.SetError(err)
+		}
+	}
+
+	out := getErrorRenderer(ctx).RenderError(ctx, err, errs)
+	if out.Headers == nil {
+		out.Headers = make(map[string]string)
+	}
+
+	for k, v := range GetErrorHeaders(err) {
+		out.Headers[k] = v
+	}
+
+	return out
+}
+
+// NewErrorResponse builds an ErrorResponse from the given error and its inner errors, as returned by errors.Split().
+// It is useful for implementing custom ErrorRenderer(s).
+func NewErrorResponse(ctx context.Context, err error, errs []error) *ErrorResponse {
 	statusCode := errors.GetHTTPStatusOrDefault(err, http.StatusInternalServerError)
 
 	resp := &ErrorResponse{
-		StatusCode:    statusCode,
-		PublicMessage: errors.GetPublicMessageOrDefault(err, getDefaultPublicMessage(statusCode)),
-		RequestID:     GetRequestContext(ctx).RequestID,
+		StatusCode:       statusCode,
+		PublicMessage:    errors.GetPublicMessageOrDefault(err, getDefaultPublicMessage(statusCode)),
+		Code:             GetErrorCode(err),
+		Details:          GetErrorDetails(err),
+		RequestID:        GetRequestContext(ctx).RequestID,
+		ValidationErrors: GetValidationErrors(err),
 	}
 
 	if GetDebug(ctx) {
-		errs := errors.Split(err)
 		resp.Errors = make([]*ErrorResponseError, len(errs))
 
 		for i, err := range errs {
@@ -64,7 +152,7 @@ func adaptError(ctx context.Context, err error) *events.APIGatewayProxyResponse
 		}
 	}
 
-	return adaptResponse(ctx, statusCode, resp)
+	return resp
 }
 
 func getDefaultPublicMessage(statusCode int) string {
@@ -77,8 +165,8 @@ func getDefaultPublicMessage(statusCode int) string {
 	return "unknown"
 }
 
-func adaptResponse(_ context.Context, statusCode int, resp interface{}) *events.APIGatewayProxyResponse {
-	out := &events.APIGatewayProxyResponse{
+func newResponse(statusCode int) *events.APIGatewayProxyResponse {
+	return &events.APIGatewayProxyResponse{
 		StatusCode: statusCode,
 		Headers: map[string]string{
 			"Content-Type":  "application/json; charset=utf-8",
@@ -87,22 +175,81 @@ func adaptResponse(_ context.Context, statusCode int, resp interface{}) *events.
 			"Expires":       "0",
 		},
 	}
+}
+
+func adaptResponse(ctx context.Context, statusCode int, resp interface{}) *events.APIGatewayProxyResponse {
+	out := newResponse(statusCode)
+	cachePolicy := getCachePolicy(ctx)
+
+	if customResp, ok := resp.(*Response); ok {
+		errors.MaybeMustWrap(adaptBody(ctx, out, customResp.Body))
+		if customResp.CachePolicy != nil {
+			cachePolicy = customResp.CachePolicy
+		}
+		cachePolicy.setHeaders(out)
+		customResp.apply(out)
+	} else {
+		errors.MaybeMustWrap(adaptBody(ctx, out, resp))
+		cachePolicy.setHeaders(out)
+	}
 
+	cachePolicy.applyETag(ctx, out)
+	return out
+}
+
+func adaptBody(ctx context.Context, out *events.APIGatewayProxyResponse, resp interface{}) error {
 	if resp == nil {
-		return out
+		return nil
 	}
 
-	if serializedResp, ok := resp.(*SerializedResponse); ok {
-		out.Headers["Content-Type"] = serializedResp.ContentType
-		out.IsBase64Encoded = serializedResp.IsBase64Encoded
-		out.Body = serializedResp.Body
-		return out
+	serializedResp, ok := resp.(*SerializedResponse)
+	if !ok {
+		var err error
+		if serializedResp, err = getResponseEncoder(ctx).Encode(resp); err != nil {
+			return err
+		}
 	}
 
-	buf, err := json.MarshalIndent(resp, "", "  ")
-	errors.MaybeMustWrap(err)
-	out.Body = string(buf)
-	out.IsBase64Encoded = false
+	out.Headers["Content-Type"] = serializedResp.ContentType
+	out.IsBase64Encoded = serializedResp.IsBase64Encoded
+	out.Body = serializedResp.Body
+	return nil
+}
 
-	return out
+// markHandledUnlessAbandoned marks the handler as rendering an error, so that its error response is returned instead of
+// the timeout one, and returns true. If the handler has already been abandoned because the deadline expired, it returns
+// false: its errors must not be reported, logged or traced, as the timeout response already was.
+func markHandledUnlessAbandoned(ctx context.Context) bool {
+	state, ok := ctx.Value(deadlineStateContextKey).(*int32)
+	if !ok {
+		return true
+	}
+
+	atomic.CompareAndSwapInt32(state, deadlinePending, deadlineHandled)
+	return atomic.LoadInt32(state) != deadlineExpired
+}
+
+// endSpan sets the error on the Span, unless the handler has been abandoned, and ends it.
+func endSpan(ctx context.Context, span *Span, err error) {
+	if err != nil && markHandledUnlessAbandoned(ctx) {
+		span.SetError(err)
+	}
+	span.End()
+}
+
+func addVary(out *events.APIGatewayProxyResponse, header string) {
+	if out.Headers == nil {
+		out.Headers = make(map[string]string)
+	}
+
+	if vary := out.Headers["Vary"]; vary != "" {
+		for _, v := range strings.Split(vary, ",") {
+			if strings.EqualFold(strings.TrimSpace(v), header) {
+				return
+			}
+		}
+		out.Headers["Vary"] = vary + ", " + header
+		return
+	}
+	out.Headers["Vary"] = header
 }
@@ -4,6 +4,7 @@ import (
 	"net/http"
 	"testing"
 
+	"github.com/aws/aws-lambda-go/events"
 	"github.com/stretchr/testify/require"
 )
 
@@ -12,3 +13,13 @@ func TestGetDefaultPublicMessage(t *testing.T) {
 	require.Equal(t, "internal-server-error", getDefaultPublicMessage(http.StatusInternalServerError))
 	require.Equal(t, "unknown", getDefaultPublicMessage(1))
 }
+
+func TestAddVary(t *testing.T) {
+	out := &events.APIGatewayProxyResponse{Headers: map[string]string{}}
+	addVary(out, "Accept-Encoding")
+	require.Equal(t, "Accept-Encoding", out.Headers["Vary"])
+	addVary(out, "Origin")
+	require.Equal(t, "Accept-Encoding, Origin", out.Headers["Vary"])
+	addVary(out, "accept-encoding")
+	require.Equal(t, "Accept-Encoding, Origin", out.Headers["Vary"])
+}
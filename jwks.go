@@ -0,0 +1,250 @@
+package mbd
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/ibrt/errors"
+)
+
+// JWTKeySource provides the keys used to verify JWT signatures. Keys are []byte for HS256, *rsa.PublicKey for RS256
+// and *ecdsa.PublicKey for ES256.
+type JWTKeySource interface {
+	GetKey(ctx context.Context, algorithm, keyID string) (interface{}, error)
+}
+
+// JWKSFetcher fetches a JSON Web Key Set document.
+type JWKSFetcher func(ctx context.Context) ([]byte, error)
+
+type staticJWTKeys map[string]interface{}
+
+type jwksKeySource struct {
+	m                  *sync.Mutex
+	fetcher            JWKSFetcher
+	ttl                time.Duration
+	minRefreshInterval time.Duration
+	keys               map[string]interface{}
+	fetchedAt          time.Time     // time of the last successful fetch
+	attemptedAt        time.Time     // time of the last fetch attempt
+	err                error         // error of the last fetch attempt, if it failed
+	inFlight           chan struct{} // closed when the fetch in progress completes, nil if none
+}
+
+type jwks struct {
+	Keys []*jwk `json:"keys"`
+}
+
+type jwk struct {
+	KeyType string `json:"kty"`
+	KeyID   string `json:"kid"`
+	Use     string `json:"use"`
+	Curve   string `json:"crv"`
+	K       string `json:"k"`
+	N       string `json:"n"`
+	E       string `json:"e"`
+	X       string `json:"x"`
+	Y       string `json:"y"`
+}
+
+const (
+	jwksMinRefreshInterval = 30 * time.Second
+)
+
+// StaticJWTKeys returns a JWTKeySource backed by the given keys, indexed by key ID. The key with ID "" is used for
+// tokens without "kid" header.
+func StaticJWTKeys(keys map[string]interface{}) JWTKeySource {
+	return staticJWTKeys(keys)
+}
+
+// GetKey implements JWTKeySource.
+func (s staticJWTKeys) GetKey(_ context.Context, _, keyID string) (interface{}, error) {
+	if key, ok := s[keyID]; ok {
+		return key, nil
+	}
+	return nil, newInvalidJWTError("unknown key")
+}
+
+// JWKSKeySource returns a JWTKeySource backed by a JSON Web Key Set document, obtained from the given fetcher and cached
+// for ttl. The document is also fetched again when a token references an unknown key ID. Fetches are attempted at most
+// every 30 seconds and shared by concurrent requests. If a fetch fails, the cached keys keep being used.
+func JWKSKeySource(fetcher JWKSFetcher, ttl time.Duration) JWTKeySource {
+	errors.Assert(fetcher != nil, "fetcher must not be nil")
+
+	return &jwksKeySource{
+		m:                  &sync.Mutex{},
+		fetcher:            fetcher,
+		ttl:                ttl,
+		minRefreshInterval: jwksMinRefreshInterval,
+	}
+}
+
+// GetKey implements JWTKeySource.
+func (s *jwksKeySource) GetKey(ctx context.Context, _, keyID string) (interface{}, error) {
+	s.m.Lock()
+	now := time.Now()
+	_, ok := s.keys[keyID]
+	stale := !ok || now.Sub(s.fetchedAt) >= s.ttl
+
+	inFlight, isFetcher := s.inFlight, false
+	if stale && inFlight == nil && now.Sub(s.attemptedAt) >= s.minRefreshInterval {
+		inFlight, isFetcher = make(chan struct{}), true
+		s.inFlight, s.attemptedAt = inFlight, now
+	}
+	s.m.Unlock()
+
+	if isFetcher {
+		s.refresh(ctx, inFlight)
+	} else if inFlight != nil && !ok {
+		// requests whose key is cached do not wait for the refresh
+		select {
+		case <-inFlight:
+		case <-ctx.Done():
+			return nil, errors.Wrap(ctx.Err())
+		}
+	}
+
+	s.m.Lock()
+	defer s.m.Unlock()
+
+	if key, ok := s.keys[keyID]; ok {
+		return key, nil
+	}
+	if s.keys == nil && s.err != nil {
+		return nil, s.err
+	}
+	return nil, newInvalidJWTError("unknown key")
+}
+
+// refresh fetches the keys outside of the lock, keeping the cached ones if the fetch fails.
+func (s *jwksKeySource) refresh(ctx context.Context, inFlight chan struct{}) {
+	var keys map[string]interface{}
+	err := errors.Errorf("JWKS fetch did not complete")
+
+	defer func() {
+		s.m.Lock()
+		if err == nil {
+			s.keys, s.fetchedAt = keys, time.Now()
+		}
+		s.err, s.inFlight = err, nil
+		s.m.Unlock()
+		close(inFlight)
+	}()
+
+	keys, err = s.fetch(ctx)
+}
+
+func (s *jwksKeySource) fetch(ctx context.Context) (map[string]interface{}, error) {
+	buf, err := s.fetcher(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, errors.Prefix("cannot fetch JWKS"))
+	}
+
+	keySet := &jwks{}
+	if err := json.Unmarshal(buf, keySet); err != nil {
+		return nil, errors.Wrap(err, errors.Prefix("invalid JWKS"))
+	}
+
+	keys := make(map[string]interface{}, len(keySet.Keys))
+	for _, k := range keySet.Keys {
+		if k.Use != "" && k.Use != "sig" {
+			continue
+		}
+
+		key, err := k.parse()
+		if err != nil {
+			return nil, errors.Wrap(err, errors.Prefix("invalid JWKS key '%v'", k.KeyID))
+		}
+		if key != nil {
+			keys[k.KeyID] = key
+		}
+	}
+
+	return keys, nil
+}
+
+// parse returns the key described by the JWK, or nil if the key type is not supported.
+func (k *jwk) parse() (interface{}, error) {
+	switch k.KeyType {
+	case "oct":
+		return base64.RawURLEncoding.DecodeString(k.K)
+	case "RSA":
+		n, err := decodeJWKInt(k.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := decodeJWKInt(k.E)
+		if err != nil {
+			return nil, err
+		}
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+	case "EC":
+		if k.Curve != "P-256" {
+			return nil, nil
+		}
+		x, err := decodeJWKInt(k.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := decodeJWKInt(k.Y)
+		if err != nil {
+			return nil, err
+		}
+		return &ecdsa.PublicKey{Curve: elliptic.P256(), X: x, Y: y}, nil
+	default:
+		return nil, nil
+	}
+}
+
+func decodeJWKInt(v string) (*big.Int, error) {
+	buf, err := base64.RawURLEncoding.DecodeString(v)
+	if err != nil {
+		return nil, errors.Wrap(err)
+	}
+	return new(big.Int).SetBytes(buf), nil
+}
+
+// FileJWKSFetcher returns a JWKSFetcher that reads the JSON Web Key Set document from a file, useful for testing.
+func FileJWKSFetcher(filePath string) JWKSFetcher {
+	return func(_ context.Context) ([]byte, error) { // JWKSFetcher
+		buf, err := ioutil.ReadFile(filePath)
+		return buf, errors.MaybeWrap(err)
+	}
+}
+
+// HTTPJWKSFetcher returns a JWKSFetcher that downloads the JSON Web Key Set document from the given URL, e.g.
+// "https://cognito-idp.<region>.amazonaws.com/<userPoolId>/.well-known/jwks.json". If client is nil,
+// http.DefaultClient is used.
+func HTTPJWKSFetcher(url string, client *http.Client) JWKSFetcher {
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	return func(ctx context.Context) ([]byte, error) { // JWKSFetcher
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+		if err != nil {
+			return nil, errors.Wrap(err)
+		}
+
+		resp, err := client.Do(req)
+		if err != nil {
+			return nil, errors.Wrap(err)
+		}
+		defer errors.IgnoreClose(resp.Body)
+
+		if resp.StatusCode != http.StatusOK {
+			return nil, errors.Errorf("unexpected status code %v", resp.StatusCode)
+		}
+
+		buf, err := ioutil.ReadAll(resp.Body)
+		return buf, errors.MaybeWrap(err)
+	}
+}
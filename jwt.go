@@ -0,0 +1,270 @@
+package mbd
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math"
+	"math/big"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/ibrt/errors"
+)
+
+// Supported JWT signing algorithms.
+const (
+	JWTAlgorithmHS256 = "HS256"
+	JWTAlgorithmRS256 = "RS256"
+	JWTAlgorithmES256 = "ES256"
+)
+
+// JWTConfig describes the configuration of JWTChecker.
+type JWTConfig struct {
+	Keys                   JWTKeySource  // required
+	Algorithms             []string      // allowed signing algorithms, defaults to all supported ones
+	Issuer                 string        // if set, the "iss" claim must match
+	Audience               string        // if set, the "aud" claim must contain it
+	Leeway                 time.Duration // clock skew tolerance for "exp" and "nbf"
+	AllowMissingExpiration bool          // if set, tokens without an "exp" claim are accepted, otherwise they are rejected
+}
+
+// JWTClaims describes the claims of a verified JWT. Registered claims are parsed, all claims are available through
+// Decode() and Get().
+type JWTClaims struct {
+	Issuer    string         `json:"iss,omitempty"`
+	Subject   string         `json:"sub,omitempty"`
+	Audience  JWTAudience    `json:"aud,omitempty"`
+	ExpiresAt JWTNumericDate `json:"exp,omitempty"`
+	NotBefore JWTNumericDate `json:"nbf,omitempty"`
+	IssuedAt  JWTNumericDate `json:"iat,omitempty"`
+	ID        string         `json:"jti,omitempty"`
+
+	raw json.RawMessage
+}
+
+// JWTNumericDate is a date claim, i.e. the number of seconds since the Unix epoch, which can be fractional.
+type JWTNumericDate float64
+
+// Time converts the JWTNumericDate to time.Time.
+func (d JWTNumericDate) Time() time.Time {
+	sec, frac := math.Modf(float64(d))
+	return time.Unix(int64(sec), int64(frac*1e9))
+}
+
+// JWTAudience is the "aud" claim, which can be encoded as a single string or as an array of strings.
+type JWTAudience []string
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (a *JWTAudience) UnmarshalJSON(buf []byte) error {
+	var audience string
+	if err := json.Unmarshal(buf, &audience); err == nil {
+		*a = JWTAudience{audience}
+		return nil
+	}
+
+	var audiences []string
+	if err := json.Unmarshal(buf, &audiences); err != nil {
+		return errors.Wrap(err)
+	}
+
+	*a = audiences
+	return nil
+}
+
+// Contains returns true if the audience contains the given value.
+func (a JWTAudience) Contains(audience string) bool {
+	for _, v := range a {
+		if v == audience {
+			return true
+		}
+	}
+	return false
+}
+
+// Decode unmarshals all claims into v, e.g. a struct with custom claims.
+func (c *JWTClaims) Decode(v interface{}) error {
+	return errors.MaybeWrap(json.Unmarshal(c.raw, v))
+}
+
+// Get returns the value of the given claim, or nil if missing.
+func (c *JWTClaims) Get(name string) interface{} {
+	claims := map[string]interface{}{}
+	errors.Ignore(json.Unmarshal(c.raw, &claims))
+	return claims[name]
+}
+
+type jwtHeader struct {
+	Algorithm string `json:"alg"`
+	KeyID     string `json:"kid"`
+}
+
+// JWTChecker returns a Checker that verifies the JWT in the "Authorization: Bearer" header, and stores its claims in the
+// context (see GetJWTClaims). Tokens must have an "exp" claim, unless config.AllowMissingExpiration is set. Requests with
+// a missing or invalid token are rejected with 401 and a WWW-Authenticate header.
+func JWTChecker(config *JWTConfig) Checker {
+	errors.Assert(config != nil && config.Keys != nil, "config.Keys must not be nil")
+
+	algorithms := config.Algorithms
+	if len(algorithms) == 0 {
+		algorithms = []string{JWTAlgorithmHS256, JWTAlgorithmRS256, JWTAlgorithmES256}
+	}
+
+	return func(ctx context.Context, _ *events.APIGatewayProxyRequest, _ interface{}) (context.Context, error) { // Checker
+		token, ok := getBearerToken(ctx)
+		if !ok {
+			return nil, errors.Errorf("missing bearer token", errors.HTTPStatusUnauthorized, ErrorHeader("WWW-Authenticate", "Bearer"))
+		}
+
+		claims, err := verifyJWT(ctx, token, config, algorithms, time.Now())
+		if err != nil {
+			return nil, err
+		}
+
+		return context.WithValue(ctx, jwtClaimsContextKey, claims), nil
+	}
+}
+
+// GetJWTClaims returns the JWTClaims stored in context by JWTChecker. If missing, it returns nil.
+func GetJWTClaims(ctx context.Context) *JWTClaims {
+	if claims, ok := ctx.Value(jwtClaimsContextKey).(*JWTClaims); ok {
+		return claims
+	}
+	return nil
+}
+
+func getBearerToken(ctx context.Context) (string, bool) {
+	authorization := strings.TrimSpace(GetHeaders(ctx).Get("Authorization"))
+	if len(authorization) < 7 || !strings.EqualFold(authorization[:7], "Bearer ") {
+		return "", false
+	}
+
+	token := strings.TrimSpace(authorization[7:])
+	return token, token != ""
+}
+
+func verifyJWT(ctx context.Context, token string, config *JWTConfig, algorithms []string, now time.Time) (*JWTClaims, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, newInvalidJWTError("malformed token")
+	}
+
+	header := &jwtHeader{}
+	if err := decodeJWTPart(parts[0], header); err != nil {
+		return nil, newInvalidJWTError("malformed header")
+	}
+
+	if !containsString(algorithms, header.Algorithm) {
+		return nil, newInvalidJWTError("unsupported algorithm")
+	}
+
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, newInvalidJWTError("malformed signature")
+	}
+
+	key, err := config.Keys.GetKey(ctx, header.Algorithm, header.KeyID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := verifyJWTSignature(header.Algorithm, key, parts[0]+"."+parts[1], signature); err != nil {
+		return nil, err
+	}
+
+	rawClaims, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return nil, newInvalidJWTError("malformed claims")
+	}
+
+	claims := &JWTClaims{raw: rawClaims}
+	if err := json.Unmarshal(rawClaims, claims); err != nil {
+		return nil, newInvalidJWTError("malformed claims")
+	}
+
+	if claims.ExpiresAt == 0 && !config.AllowMissingExpiration {
+		return nil, newInvalidJWTError("missing expiration")
+	}
+
+	if claims.ExpiresAt != 0 && !now.Before(claims.ExpiresAt.Time().Add(config.Leeway)) {
+		return nil, newInvalidJWTError("token expired")
+	}
+
+	if claims.NotBefore != 0 && now.Add(config.Leeway).Before(claims.NotBefore.Time()) {
+		return nil, newInvalidJWTError("token not yet valid")
+	}
+
+	if config.Issuer != "" && claims.Issuer != config.Issuer {
+		return nil, newInvalidJWTError("invalid issuer")
+	}
+
+	if config.Audience != "" && !claims.Audience.Contains(config.Audience) {
+		return nil, newInvalidJWTError("invalid audience")
+	}
+
+	return claims, nil
+}
+
+// newInvalidJWTError returns a 401 error with a WWW-Authenticate header describing why the token is invalid.
+func newInvalidJWTError(description string) error {
+	return errors.Errorf("invalid bearer token: %v", description,
+		errors.HTTPStatusUnauthorized,
+		ErrorHeader("WWW-Authenticate", fmt.Sprintf(`Bearer error="invalid_token", error_description="%v"`, description)))
+}
+
+func decodeJWTPart(part string, v interface{}) error {
+	buf, err := base64.RawURLEncoding.DecodeString(part)
+	if err != nil {
+		return errors.Wrap(err)
+	}
+	return errors.MaybeWrap(json.Unmarshal(buf, v))
+}
+
+func verifyJWTSignature(algorithm string, key interface{}, signingInput string, signature []byte) error {
+	hash := sha256.Sum256([]byte(signingInput))
+
+	switch algorithm {
+	case JWTAlgorithmHS256:
+		if secret, ok := key.([]byte); ok {
+			mac := hmac.New(sha256.New, secret)
+			mac.Write([]byte(signingInput))
+			if hmac.Equal(mac.Sum(nil), signature) {
+				return nil
+			}
+			return newInvalidJWTError("invalid signature")
+		}
+	case JWTAlgorithmRS256:
+		if publicKey, ok := key.(*rsa.PublicKey); ok {
+			if rsa.VerifyPKCS1v15(publicKey, crypto.SHA256, hash[:], signature) == nil {
+				return nil
+			}
+			return newInvalidJWTError("invalid signature")
+		}
+	case JWTAlgorithmES256:
+		if publicKey, ok := key.(*ecdsa.PublicKey); ok && publicKey.Curve == elliptic.P256() {
+			if len(signature) == 64 && ecdsa.Verify(publicKey, hash[:], new(big.Int).SetBytes(signature[:32]), new(big.Int).SetBytes(signature[32:])) {
+				return nil
+			}
+			return newInvalidJWTError("invalid signature")
+		}
+	}
+
+	return newInvalidJWTError("key does not match algorithm")
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,308 @@
+package mbd
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/ibrt/errors"
+	"github.com/stretchr/testify/require"
+)
+
+type jwtTestClaims struct {
+	Subject string `json:"sub"`
+	Role    string `json:"role"`
+}
+
+func signJWTTest(t *testing.T, algorithm, keyID string, key interface{}, claims map[string]interface{}) string {
+	header := map[string]interface{}{"alg": algorithm, "typ": "JWT"}
+	if keyID != "" {
+		header["kid"] = keyID
+	}
+
+	headerBuf, err := json.Marshal(header)
+	require.NoError(t, err)
+	claimsBuf, err := json.Marshal(claims)
+	require.NoError(t, err)
+
+	signingInput := base64.RawURLEncoding.EncodeToString(headerBuf) + "." + base64.RawURLEncoding.EncodeToString(claimsBuf)
+	hash := sha256.Sum256([]byte(signingInput))
+
+	var signature []byte
+	switch algorithm {
+	case JWTAlgorithmHS256:
+		mac := hmac.New(sha256.New, key.([]byte))
+		mac.Write([]byte(signingInput))
+		signature = mac.Sum(nil)
+	case JWTAlgorithmRS256:
+		signature, err = rsa.SignPKCS1v15(rand.Reader, key.(*rsa.PrivateKey), crypto.SHA256, hash[:])
+		require.NoError(t, err)
+	case JWTAlgorithmES256:
+		r, s, err := ecdsa.Sign(rand.Reader, key.(*ecdsa.PrivateKey), hash[:])
+		require.NoError(t, err)
+		signature = make([]byte, 64)
+		r.FillBytes(signature[:32])
+		s.FillBytes(signature[32:])
+	default:
+		signature = []byte("signature")
+	}
+
+	return signingInput + "." + base64.RawURLEncoding.EncodeToString(signature)
+}
+
+func newJWTTestFunction(config *JWTConfig) *Function {
+	return NewFunction(nil, func(ctx context.Context, req interface{}) (interface{}, error) {
+		claims := &jwtTestClaims{}
+		if err := GetJWTClaims(ctx).Decode(claims); err != nil {
+			return nil, err
+		}
+		return claims, nil
+	}).AddCheckers(JWTChecker(config))
+}
+
+func callJWTTestFunction(t *testing.T, f *Function, authorization string) events.APIGatewayProxyResponse {
+	out, err := f.Handler(context.Background(), events.APIGatewayProxyRequest{
+		Headers: map[string]string{"Authorization": authorization},
+	})
+	require.NoError(t, err)
+	return out
+}
+
+func TestJWTChecker(t *testing.T) {
+	secret := []byte("secret")
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	f := newJWTTestFunction(&JWTConfig{
+		Keys: StaticJWTKeys(map[string]interface{}{
+			"":    secret,
+			"rsa": &rsaKey.PublicKey,
+			"ec":  &ecKey.PublicKey,
+		}),
+		Issuer:   "test-issuer",
+		Audience: "test-audience",
+		Leeway:   time.Minute,
+	})
+
+	claims := map[string]interface{}{
+		"iss":  "test-issuer",
+		"aud":  []string{"other", "test-audience"},
+		"sub":  "test-subject",
+		"exp":  time.Now().Add(time.Hour).Unix(),
+		"nbf":  time.Now().Add(30 * time.Second).Unix(),
+		"role": "admin",
+	}
+
+	for _, token := range []string{
+		signJWTTest(t, JWTAlgorithmHS256, "", secret, claims),
+		signJWTTest(t, JWTAlgorithmRS256, "rsa", rsaKey, claims),
+		signJWTTest(t, JWTAlgorithmES256, "ec", ecKey, claims),
+	} {
+		out := callJWTTestFunction(t, f, "bearer "+token)
+		require.Equal(t, http.StatusOK, out.StatusCode, out.Body)
+		require.JSONEq(t, `{"sub":"test-subject","role":"admin"}`, out.Body)
+	}
+
+	out := callJWTTestFunction(t, f, "")
+	require.Equal(t, http.StatusUnauthorized, out.StatusCode)
+	require.Equal(t, "Bearer", out.Headers["WWW-Authenticate"])
+	require.JSONEq(t, `{"statusCode":401,"publicMessage":"unauthorized","requestId":""}`, out.Body)
+
+	for description, token := range map[string]string{
+		"malformed token":              "invalid",
+		"malformed header":             "invalid.e30.e30",
+		"unsupported algorithm":        signJWTTest(t, "none", "", nil, claims),
+		"unknown key":                  signJWTTest(t, JWTAlgorithmHS256, "unknown", secret, claims),
+		"invalid signature":            signJWTTest(t, JWTAlgorithmHS256, "", []byte("other"), claims),
+		"key does not match algorithm": signJWTTest(t, JWTAlgorithmHS256, "rsa", secret, claims),
+		"token expired":                signJWTTest(t, JWTAlgorithmHS256, "", secret, map[string]interface{}{"iss": "test-issuer", "aud": "test-audience", "exp": time.Now().Add(-2 * time.Minute).Unix()}),
+		"token not yet valid":          signJWTTest(t, JWTAlgorithmHS256, "", secret, map[string]interface{}{"iss": "test-issuer", "aud": "test-audience", "exp": claims["exp"], "nbf": time.Now().Add(2 * time.Minute).Unix()}),
+		"missing expiration":           signJWTTest(t, JWTAlgorithmHS256, "", secret, map[string]interface{}{"iss": "test-issuer", "aud": "test-audience"}),
+		"invalid issuer":               signJWTTest(t, JWTAlgorithmHS256, "", secret, map[string]interface{}{"iss": "other", "aud": "test-audience", "exp": claims["exp"]}),
+		"invalid audience":             signJWTTest(t, JWTAlgorithmHS256, "", secret, map[string]interface{}{"iss": "test-issuer", "aud": "other", "exp": claims["exp"]}),
+	} {
+		out := callJWTTestFunction(t, f, "Bearer "+token)
+		require.Equal(t, http.StatusUnauthorized, out.StatusCode, description)
+		require.Equal(t, `Bearer error="invalid_token", error_description="`+description+`"`, out.Headers["WWW-Authenticate"])
+	}
+}
+
+func TestJWTChecker_Expiration(t *testing.T) {
+	secret := []byte("secret")
+	now := float64(time.Now().UnixNano()) / 1e9
+
+	f := newJWTTestFunction(&JWTConfig{Keys: StaticJWTKeys(map[string]interface{}{"": secret})})
+	out := callJWTTestFunction(t, f, "Bearer "+signJWTTest(t, JWTAlgorithmHS256, "", secret, map[string]interface{}{"sub": "s"}))
+	require.Equal(t, http.StatusUnauthorized, out.StatusCode)
+	require.Equal(t, `Bearer error="invalid_token", error_description="missing expiration"`, out.Headers["WWW-Authenticate"])
+
+	out = callJWTTestFunction(t, f, "Bearer "+signJWTTest(t, JWTAlgorithmHS256, "", secret, map[string]interface{}{"exp": now + 60.5, "nbf": now - 0.5}))
+	require.Equal(t, http.StatusOK, out.StatusCode, out.Body)
+
+	out = callJWTTestFunction(t, f, "Bearer "+signJWTTest(t, JWTAlgorithmHS256, "", secret, map[string]interface{}{"exp": now - 0.5}))
+	require.Equal(t, http.StatusUnauthorized, out.StatusCode)
+	require.Equal(t, `Bearer error="invalid_token", error_description="token expired"`, out.Headers["WWW-Authenticate"])
+
+	f = newJWTTestFunction(&JWTConfig{Keys: StaticJWTKeys(map[string]interface{}{"": secret}), AllowMissingExpiration: true})
+	out = callJWTTestFunction(t, f, "Bearer "+signJWTTest(t, JWTAlgorithmHS256, "", secret, map[string]interface{}{"sub": "s"}))
+	require.Equal(t, http.StatusOK, out.StatusCode, out.Body)
+}
+
+func TestJWTNumericDate(t *testing.T) {
+	require.Equal(t, time.Unix(1500000000, 500000000), JWTNumericDate(1500000000.5).Time())
+	require.Equal(t, time.Unix(1500000000, 0), JWTNumericDate(1500000000).Time())
+}
+
+func TestJWKSKeySource(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	encode := func(i *big.Int) string { return base64.RawURLEncoding.EncodeToString(i.Bytes()) }
+	buf, err := json.Marshal(map[string]interface{}{
+		"keys": []map[string]interface{}{
+			{"kty": "RSA", "kid": "rsa", "use": "sig", "n": encode(rsaKey.N), "e": encode(big.NewInt(int64(rsaKey.E)))},
+			{"kty": "EC", "kid": "ec", "crv": "P-256", "x": encode(ecKey.X), "y": encode(ecKey.Y)},
+			{"kty": "oct", "kid": "oct", "k": base64.RawURLEncoding.EncodeToString([]byte("secret"))},
+			{"kty": "RSA", "kid": "enc", "use": "enc", "n": encode(rsaKey.N), "e": "AQAB"},
+			{"kty": "OKP", "kid": "okp"},
+		},
+	})
+	require.NoError(t, err)
+
+	filePath := filepath.Join(t.TempDir(), "jwks.json")
+	require.NoError(t, ioutil.WriteFile(filePath, buf, 0600))
+
+	fetches := 0
+	fileFetcher := FileJWKSFetcher(filePath)
+	keys := JWKSKeySource(func(ctx context.Context) ([]byte, error) {
+		fetches++
+		return fileFetcher(ctx)
+	}, time.Hour)
+
+	f := newJWTTestFunction(&JWTConfig{Keys: keys, Algorithms: []string{JWTAlgorithmRS256, JWTAlgorithmES256}})
+	claims := map[string]interface{}{"sub": "test-subject", "exp": time.Now().Add(time.Hour).Unix()}
+
+	out := callJWTTestFunction(t, f, "Bearer "+signJWTTest(t, JWTAlgorithmRS256, "rsa", rsaKey, claims))
+	require.Equal(t, http.StatusOK, out.StatusCode, out.Body)
+	out = callJWTTestFunction(t, f, "Bearer "+signJWTTest(t, JWTAlgorithmES256, "ec", ecKey, claims))
+	require.Equal(t, http.StatusOK, out.StatusCode, out.Body)
+	out = callJWTTestFunction(t, f, "Bearer "+signJWTTest(t, JWTAlgorithmHS256, "oct", []byte("secret"), claims))
+	require.Equal(t, http.StatusUnauthorized, out.StatusCode)
+	out = callJWTTestFunction(t, f, "Bearer "+signJWTTest(t, JWTAlgorithmRS256, "enc", rsaKey, claims))
+	require.Equal(t, http.StatusUnauthorized, out.StatusCode)
+	require.Equal(t, 1, fetches)
+
+	key, err := keys.GetKey(context.Background(), JWTAlgorithmHS256, "oct")
+	require.NoError(t, err)
+	require.Equal(t, []byte("secret"), key)
+
+	f = newJWTTestFunction(&JWTConfig{Keys: JWKSKeySource(FileJWKSFetcher(filepath.Join(t.TempDir(), "missing.json")), time.Hour)})
+	out = callJWTTestFunction(t, f, "Bearer "+signJWTTest(t, JWTAlgorithmRS256, "rsa", rsaKey, claims))
+	require.Equal(t, http.StatusInternalServerError, out.StatusCode)
+	require.Empty(t, out.Headers["WWW-Authenticate"])
+}
+
+func TestJWKSKeySource_Refresh(t *testing.T) {
+	secret := []byte("secret")
+	buf, err := json.Marshal(map[string]interface{}{
+		"keys": []map[string]interface{}{
+			{"kty": "oct", "kid": "oct", "k": base64.RawURLEncoding.EncodeToString(secret)},
+		},
+	})
+	require.NoError(t, err)
+
+	var fetches int32
+	var fetchErr error
+	release := make(chan struct{})
+
+	keys := JWKSKeySource(func(ctx context.Context) ([]byte, error) {
+		atomic.AddInt32(&fetches, 1)
+		<-release
+		return buf, fetchErr
+	}, 0)
+	keys.(*jwksKeySource).minRefreshInterval = 0
+
+	wg := &sync.WaitGroup{}
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			key, err := keys.GetKey(context.Background(), JWTAlgorithmHS256, "oct")
+			require.NoError(t, err)
+			require.Equal(t, secret, key)
+		}()
+	}
+
+	time.Sleep(10 * time.Millisecond)
+	close(release)
+	wg.Wait()
+	require.Equal(t, int32(1), atomic.LoadInt32(&fetches))
+
+	fetchErr = errors.Errorf("fetch failed")
+	key, err := keys.GetKey(context.Background(), JWTAlgorithmHS256, "oct")
+	require.NoError(t, err)
+	require.Equal(t, secret, key)
+	require.Equal(t, int32(2), atomic.LoadInt32(&fetches))
+
+	_, err = keys.GetKey(context.Background(), JWTAlgorithmHS256, "unknown")
+	require.Equal(t, http.StatusUnauthorized, errors.GetHTTPStatus(err))
+	require.Equal(t, int32(3), atomic.LoadInt32(&fetches))
+
+	keys.(*jwksKeySource).minRefreshInterval = time.Hour
+	key, err = keys.GetKey(context.Background(), JWTAlgorithmHS256, "oct")
+	require.NoError(t, err)
+	require.Equal(t, secret, key)
+	require.Equal(t, int32(3), atomic.LoadInt32(&fetches))
+
+	keys = JWKSKeySource(func(ctx context.Context) ([]byte, error) {
+		atomic.AddInt32(&fetches, 1)
+		return nil, errors.Errorf("fetch failed")
+	}, time.Hour)
+
+	for i := 0; i < 2; i++ {
+		_, err = keys.GetKey(context.Background(), JWTAlgorithmHS256, "oct")
+		require.EqualError(t, err, "cannot fetch JWKS: fetch failed")
+		require.Equal(t, http.StatusInternalServerError, errors.GetHTTPStatusOrDefault(err, http.StatusInternalServerError))
+	}
+	require.Equal(t, int32(4), atomic.LoadInt32(&fetches))
+}
+
+func TestHTTPJWKSFetcher(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/jwks.json" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		_, err := w.Write([]byte(`{"keys":[]}`))
+		errors.Ignore(err)
+	}))
+	defer srv.Close()
+
+	buf, err := HTTPJWKSFetcher(srv.URL+"/jwks.json", nil)(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, `{"keys":[]}`, string(buf))
+
+	_, err = HTTPJWKSFetcher(srv.URL+"/other", srv.Client())(context.Background())
+	require.EqualError(t, err, "unexpected status code 404")
+}
@@ -0,0 +1,156 @@
+package mbd
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/ibrt/errors"
+)
+
+// LogLevel describes the severity of a log entry.
+type LogLevel string
+
+// Known log levels.
+const (
+	LogLevelDebug LogLevel = "debug"
+	LogLevelInfo  LogLevel = "info"
+	LogLevelWarn  LogLevel = "warn"
+	LogLevelError LogLevel = "error"
+)
+
+// LogFields describes the structured fields of a log entry.
+type LogFields map[string]interface{}
+
+// Logger describes a structured logger.
+type Logger interface {
+	Log(level LogLevel, message string, fields LogFields)
+	WithFields(fields LogFields) Logger
+}
+
+type accessLog struct {
+	m             sync.Mutex
+	publicMessage string
+}
+
+var (
+	nopLogger Logger = &jsonLogger{}
+)
+
+// NewJSONLogger returns a Logger that writes each entry to w as a single line of JSON, e.g. to os.Stdout to have it
+// collected by CloudWatch Logs.
+func NewJSONLogger(w io.Writer) Logger {
+	return &jsonLogger{
+		m: &sync.Mutex{},
+		w: w,
+	}
+}
+
+// NopLogger returns a Logger that discards all entries.
+func NopLogger() Logger {
+	return nopLogger
+}
+
+type jsonLogger struct {
+	m      *sync.Mutex
+	w      io.Writer
+	fields LogFields
+}
+
+// Log implements Logger.
+func (l *jsonLogger) Log(level LogLevel, message string, fields LogFields) {
+	if l.w == nil {
+		return
+	}
+
+	entry := make(LogFields, len(l.fields)+len(fields)+3)
+	for k, v := range l.fields {
+		entry[k] = v
+	}
+	for k, v := range fields {
+		entry[k] = v
+	}
+	entry["timestamp"] = time.Now().UTC()
+	entry["level"] = level
+	entry["message"] = message
+
+	buf, err := json.Marshal(entry)
+	errors.MaybeMustWrap(err)
+
+	l.m.Lock()
+	defer l.m.Unlock()
+	_, err = l.w.Write(append(buf, '\n'))
+	errors.Ignore(err)
+}
+
+// WithFields implements Logger.
+func (l *jsonLogger) WithFields(fields LogFields) Logger {
+	newFields := make(LogFields, len(l.fields)+len(fields))
+	for k, v := range l.fields {
+		newFields[k] = v
+	}
+	for k, v := range fields {
+		newFields[k] = v
+	}
+
+	return &jsonLogger{
+		m:      l.m,
+		w:      l.w,
+		fields: newFields,
+	}
+}
+
+// GetLogger returns the request-scoped Logger stored in context, which includes the request ID in all entries.
+// If missing, it returns NopLogger().
+func GetLogger(ctx context.Context) Logger {
+	if logger, ok := ctx.Value(loggerContextKey).(Logger); ok {
+		return logger
+	}
+	return nopLogger
+}
+
+// newRequestLogger returns a Logger derived from the given one, that includes the request ID in all entries.
+func newRequestLogger(logger Logger, in *events.APIGatewayProxyRequest) Logger {
+	return logger.WithFields(LogFields{"requestId": in.RequestContext.RequestID})
+}
+
+// setAccessLogPublicMessage records the public message of an error response in the access log, if enabled.
+func setAccessLogPublicMessage(ctx context.Context, publicMessage string) {
+	if accessLog, ok := ctx.Value(accessLogContextKey).(*accessLog); ok {
+		accessLog.m.Lock()
+		defer accessLog.m.Unlock()
+		accessLog.publicMessage = publicMessage
+	}
+}
+
+// writeAccessLog writes an access log entry for the given request and response to the request-scoped Logger.
+func writeAccessLog(ctx context.Context, in *events.APIGatewayProxyRequest, out *events.APIGatewayProxyResponse, start time.Time) {
+	fields := LogFields{
+		"method":     in.HTTPMethod,
+		"resource":   in.Resource,
+		"path":       in.Path,
+		"statusCode": out.StatusCode,
+		"latencyMs":  float64(time.Since(start).Microseconds()) / 1000,
+		"sourceIp":   in.RequestContext.Identity.SourceIP,
+		"userAgent":  in.RequestContext.Identity.UserAgent,
+	}
+
+	if accessLog, ok := ctx.Value(accessLogContextKey).(*accessLog); ok {
+		accessLog.m.Lock()
+		defer accessLog.m.Unlock()
+		if accessLog.publicMessage != "" {
+			fields["publicMessage"] = accessLog.publicMessage
+		}
+	}
+
+	level := LogLevelInfo
+	if out.StatusCode >= http.StatusInternalServerError {
+		level = LogLevelError
+	}
+
+	GetLogger(ctx).Log(level, "access", fields)
+}
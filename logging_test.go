@@ -0,0 +1,101 @@
+package mbd
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/ibrt/errors"
+	"github.com/stretchr/testify/require"
+)
+
+func TestJSONLogger(t *testing.T) {
+	buf := &bytes.Buffer{}
+	logger := NewJSONLogger(buf)
+	logger.WithFields(LogFields{"k1": "v1", "k2": "v2"}).WithFields(LogFields{"k2": "v3"}).Log(LogLevelWarn, "test message", LogFields{"k3": 1})
+	logger.Log(LogLevelInfo, "other message", nil)
+
+	lines := strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n")
+	require.Len(t, lines, 2)
+
+	entry := map[string]interface{}{}
+	require.NoError(t, json.Unmarshal([]byte(lines[0]), &entry))
+	require.NotEmpty(t, entry["timestamp"])
+	delete(entry, "timestamp")
+	require.Equal(t, map[string]interface{}{"level": "warn", "message": "test message", "k1": "v1", "k2": "v3", "k3": 1.0}, entry)
+
+	entry = map[string]interface{}{}
+	require.NoError(t, json.Unmarshal([]byte(lines[1]), &entry))
+	delete(entry, "timestamp")
+	require.Equal(t, map[string]interface{}{"level": "info", "message": "other message"}, entry)
+}
+
+func TestGetLogger(t *testing.T) {
+	require.Equal(t, NopLogger(), GetLogger(context.Background()))
+	NopLogger().WithFields(LogFields{"k": "v"}).Log(LogLevelInfo, "discarded", nil)
+}
+
+func TestFunction_Logger(t *testing.T) {
+	buf := &bytes.Buffer{}
+
+	f := NewFunction(nil, func(ctx context.Context, req interface{}) (interface{}, error) {
+		GetLogger(ctx).Log(LogLevelInfo, "handler", LogFields{"k": "v"})
+		if GetPath(ctx).Path == "/error" {
+			return nil, errors.Errorf("test error", errors.HTTPStatusConflict, errors.PublicMessage("test-error"))
+		}
+		return nil, nil
+	}).SetLogger(NewJSONLogger(buf))
+
+	in := events.APIGatewayProxyRequest{
+		Resource:   "/{proxy+}",
+		Path:       "/ok",
+		HTTPMethod: http.MethodGet,
+		RequestContext: events.APIGatewayProxyRequestContext{
+			RequestID: "test",
+			Identity: events.APIGatewayRequestIdentity{
+				SourceIP:  "127.0.0.1",
+				UserAgent: "test-agent",
+			},
+		},
+	}
+
+	_, err := f.Handler(context.Background(), in)
+	require.NoError(t, err)
+	in.Path = "/error"
+	_, err = f.Handler(context.Background(), in)
+	require.NoError(t, err)
+
+	lines := strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n")
+	require.Len(t, lines, 4)
+
+	entries := make([]map[string]interface{}, len(lines))
+	for i, line := range lines {
+		require.NoError(t, json.Unmarshal([]byte(line), &entries[i]))
+		delete(entries[i], "timestamp")
+		require.Equal(t, "test", entries[i]["requestId"])
+	}
+
+	require.Equal(t, map[string]interface{}{"level": "info", "message": "handler", "requestId": "test", "k": "v"}, entries[0])
+
+	require.IsType(t, 0.0, entries[1]["latencyMs"])
+	delete(entries[1], "latencyMs")
+	require.Equal(t, map[string]interface{}{
+		"level":      "info",
+		"message":    "access",
+		"requestId":  "test",
+		"method":     "GET",
+		"resource":   "/{proxy+}",
+		"path":       "/ok",
+		"statusCode": 200.0,
+		"sourceIp":   "127.0.0.1",
+		"userAgent":  "test-agent",
+	}, entries[1])
+
+	require.Equal(t, "/error", entries[3]["path"])
+	require.Equal(t, 409.0, entries[3]["statusCode"])
+	require.Equal(t, "test-error", entries[3]["publicMessage"])
+}
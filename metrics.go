@@ -0,0 +1,183 @@
+package mbd
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"math"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/ibrt/errors"
+)
+
+// MetricUnit describes the unit of a metric, as defined by CloudWatch.
+type MetricUnit string
+
+// Known metric units.
+const (
+	MetricUnitNone         MetricUnit = "None"
+	MetricUnitCount        MetricUnit = "Count"
+	MetricUnitPercent      MetricUnit = "Percent"
+	MetricUnitBytes        MetricUnit = "Bytes"
+	MetricUnitSeconds      MetricUnit = "Seconds"
+	MetricUnitMilliseconds MetricUnit = "Milliseconds"
+)
+
+// Built-in metric names.
+const (
+	LatencyMetric   = "Latency"
+	Status2xxMetric = "2xx"
+	Status4xxMetric = "4xx"
+	Status5xxMetric = "5xx"
+	ColdStartMetric = "ColdStart"
+	PanicMetric     = "Panic"
+)
+
+// Metrics collects the metrics of a single invocation. They are flushed at the end of the invocation in CloudWatch
+// Embedded Metric Format, with the Resource and Method dimensions plus any added dimension.
+type Metrics struct {
+	m          *sync.Mutex
+	dimensions map[string]string
+	values     map[string]float64
+	units      map[string]MetricUnit
+}
+
+func newMetrics() *Metrics {
+	return &Metrics{
+		m:          &sync.Mutex{},
+		dimensions: make(map[string]string),
+		values:     make(map[string]float64),
+		units:      make(map[string]MetricUnit),
+	}
+}
+
+// AddDimension adds a dimension to all metrics of the invocation.
+func (m *Metrics) AddDimension(name, value string) *Metrics {
+	m.m.Lock()
+	defer m.m.Unlock()
+	m.dimensions[name] = value
+	return m
+}
+
+// AddCount adds delta to the given counter metric. Non-finite deltas are ignored.
+func (m *Metrics) AddCount(name string, delta float64) *Metrics {
+	if !isFinite(delta) {
+		return m
+	}
+
+	m.m.Lock()
+	defer m.m.Unlock()
+	m.values[name] += delta
+	m.units[name] = MetricUnitCount
+	return m
+}
+
+// SetGauge sets the given gauge metric, replacing any previous value. Non-finite values are ignored.
+func (m *Metrics) SetGauge(name string, value float64, unit MetricUnit) *Metrics {
+	if !isFinite(value) {
+		return m
+	}
+
+	m.m.Lock()
+	defer m.m.Unlock()
+	m.values[name] = value
+	m.units[name] = unit
+	return m
+}
+
+// flush writes the metrics to w as a single line of CloudWatch Embedded Metric Format JSON. Metrics whose value is not
+// finite (e.g. overflown counters) are skipped, as they cannot be encoded.
+func (m *Metrics) flush(w io.Writer, namespace string) {
+	m.m.Lock()
+	defer m.m.Unlock()
+
+	dimensionNames := make([]string, 0, len(m.dimensions))
+	for name := range m.dimensions {
+		dimensionNames = append(dimensionNames, name)
+	}
+	sort.Strings(dimensionNames)
+
+	metricNames := make([]string, 0, len(m.values))
+	for name, value := range m.values {
+		if isFinite(value) {
+			metricNames = append(metricNames, name)
+		}
+	}
+	sort.Strings(metricNames)
+
+	definitions := make([]map[string]interface{}, len(metricNames))
+	doc := make(map[string]interface{}, len(m.dimensions)+len(metricNames)+1)
+
+	for i, name := range metricNames {
+		definitions[i] = map[string]interface{}{"Name": name, "Unit": m.units[name]}
+		doc[name] = m.values[name]
+	}
+	for name, value := range m.dimensions {
+		doc[name] = value
+	}
+
+	doc["_aws"] = map[string]interface{}{
+		"Timestamp": time.Now().UnixNano() / int64(time.Millisecond),
+		"CloudWatchMetrics": []interface{}{
+			map[string]interface{}{
+				"Namespace":  namespace,
+				"Dimensions": [][]string{dimensionNames},
+				"Metrics":    definitions,
+			},
+		},
+	}
+
+	buf, err := json.Marshal(doc)
+	if err != nil {
+		return
+	}
+
+	_, err = w.Write(append(buf, '\n'))
+	errors.Ignore(err)
+}
+
+func isFinite(value float64) bool {
+	return !math.IsNaN(value) && !math.IsInf(value, 0)
+}
+
+// recordMetrics records the built-in metrics of an invocation.
+func recordMetrics(metrics *Metrics, out *events.APIGatewayProxyResponse, latency time.Duration, coldStart bool) {
+	metrics.SetGauge(LatencyMetric, float64(latency.Microseconds())/1000, MetricUnitMilliseconds)
+
+	for _, statusMetric := range []string{Status2xxMetric, Status4xxMetric, Status5xxMetric} {
+		metrics.AddCount(statusMetric, 0)
+	}
+	switch out.StatusCode / 100 {
+	case 2:
+		metrics.AddCount(Status2xxMetric, 1)
+	case 4:
+		metrics.AddCount(Status4xxMetric, 1)
+	case 5:
+		metrics.AddCount(Status5xxMetric, 1)
+	}
+
+	if coldStart {
+		metrics.AddCount(ColdStartMetric, 1)
+	} else {
+		metrics.AddCount(ColdStartMetric, 0)
+	}
+}
+
+// GetMetrics returns the Metrics of the current invocation stored in context. If metrics are disabled, it returns a
+// Metrics that is never flushed.
+func GetMetrics(ctx context.Context) *Metrics {
+	if metrics, ok := ctx.Value(metricsContextKey).(*Metrics); ok {
+		return metrics
+	}
+	return newMetrics()
+}
+
+// recordPanic increments the Panic metric of the current invocation, if enabled.
+func recordPanic(ctx context.Context) {
+	if metrics, ok := ctx.Value(metricsContextKey).(*Metrics); ok {
+		metrics.AddCount(PanicMetric, 1)
+	}
+}
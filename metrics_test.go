@@ -0,0 +1,133 @@
+package mbd
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"math"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/ibrt/errors"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMetrics_Flush(t *testing.T) {
+	buf := &bytes.Buffer{}
+	newMetrics().
+		AddDimension("D", "d").
+		AddCount("C", 1).
+		AddCount("C", 2).
+		SetGauge("G", 1, MetricUnitBytes).
+		SetGauge("G", 2, MetricUnitBytes).
+		flush(buf, "test")
+
+	doc := map[string]interface{}{}
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &doc))
+	require.NotZero(t, doc["_aws"].(map[string]interface{})["Timestamp"])
+	delete(doc["_aws"].(map[string]interface{}), "Timestamp")
+
+	require.Equal(t, map[string]interface{}{
+		"_aws": map[string]interface{}{
+			"CloudWatchMetrics": []interface{}{
+				map[string]interface{}{
+					"Namespace":  "test",
+					"Dimensions": []interface{}{[]interface{}{"D"}},
+					"Metrics": []interface{}{
+						map[string]interface{}{"Name": "C", "Unit": "Count"},
+						map[string]interface{}{"Name": "G", "Unit": "Bytes"},
+					},
+				},
+			},
+		},
+		"D": "d",
+		"C": 3.0,
+		"G": 2.0,
+	}, doc)
+}
+
+func TestMetrics_NonFinite(t *testing.T) {
+	buf := &bytes.Buffer{}
+	newMetrics().
+		AddCount("C", 1).
+		AddCount("C", math.Inf(1)).
+		SetGauge("G", math.NaN(), MetricUnitNone).
+		SetGauge("O", math.MaxFloat64, MetricUnitNone).
+		AddCount("O", math.MaxFloat64).
+		flush(buf, "test")
+
+	doc := map[string]interface{}{}
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &doc))
+	require.Equal(t, 1.0, doc["C"])
+	require.NotContains(t, doc, "G")
+	require.NotContains(t, doc, "O")
+	require.Equal(t, []interface{}{map[string]interface{}{"Name": "C", "Unit": "Count"}},
+		doc["_aws"].(map[string]interface{})["CloudWatchMetrics"].([]interface{})[0].(map[string]interface{})["Metrics"])
+
+	f := NewFunction(nil, func(ctx context.Context, req interface{}) (interface{}, error) {
+		GetMetrics(ctx).SetGauge("G", math.NaN(), MetricUnitNone)
+		return nil, nil
+	}).SetMetrics("test", buf)
+
+	out, err := f.Handler(context.Background(), events.APIGatewayProxyRequest{})
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, out.StatusCode)
+}
+
+func TestGetMetrics(t *testing.T) {
+	require.NotNil(t, GetMetrics(context.Background()))
+	GetMetrics(context.Background()).AddCount("C", 1)
+}
+
+func TestFunction_Metrics(t *testing.T) {
+	buf := &bytes.Buffer{}
+
+	f := NewFunction(nil, func(ctx context.Context, req interface{}) (interface{}, error) {
+		GetMetrics(ctx).AddDimension("Tenant", "t").AddCount("Custom", 1)
+
+		switch GetPath(ctx).Path {
+		case "/panic":
+			panic("test panic")
+		case "/error":
+			return nil, errors.Errorf("test error", errors.HTTPStatusBadRequest)
+		default:
+			return nil, nil
+		}
+	}).SetMetrics("test", buf)
+
+	for _, path := range []string{"/ok", "/error", "/panic"} {
+		_, err := f.Handler(context.Background(), events.APIGatewayProxyRequest{
+			Resource:   "/{proxy+}",
+			Path:       path,
+			HTTPMethod: http.MethodGet,
+		})
+		require.NoError(t, err)
+	}
+
+	lines := strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n")
+	require.Len(t, lines, 3)
+
+	docs := make([]map[string]interface{}, len(lines))
+	for i, line := range lines {
+		require.NoError(t, json.Unmarshal([]byte(line), &docs[i]))
+		require.Equal(t, []interface{}{[]interface{}{"Method", "Resource", "Tenant"}},
+			docs[i]["_aws"].(map[string]interface{})["CloudWatchMetrics"].([]interface{})[0].(map[string]interface{})["Dimensions"])
+		require.Equal(t, "/{proxy+}", docs[i]["Resource"])
+		require.Equal(t, "GET", docs[i]["Method"])
+		require.Equal(t, "t", docs[i]["Tenant"])
+		require.Equal(t, 1.0, docs[i]["Custom"])
+		require.IsType(t, 0.0, docs[i]["Latency"])
+	}
+
+	for i, expected := range []map[string]float64{
+		{"2xx": 1, "4xx": 0, "5xx": 0, "ColdStart": 1, "Panic": 0},
+		{"2xx": 0, "4xx": 1, "5xx": 0, "ColdStart": 0, "Panic": 0},
+		{"2xx": 0, "4xx": 0, "5xx": 1, "ColdStart": 0, "Panic": 1},
+	} {
+		for name, value := range expected {
+			require.Equal(t, value, docs[i][name], "%v: %v", i, name)
+		}
+	}
+}
@@ -0,0 +1,196 @@
+package mbd
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"io/ioutil"
+	"mime"
+	"mime/multipart"
+	"net/url"
+	"reflect"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/ibrt/errors"
+)
+
+const (
+	fileTag = "file"
+)
+
+var (
+	fileType      = reflect.TypeOf(&File{})
+	fileSliceType = reflect.TypeOf([]*File{})
+)
+
+// File describes a file uploaded as part of a multipart/form-data request. Request struct fields of type *File or
+// []*File tagged with `file:"name"` are populated with the file parts with the given form name. Other tagged fields are
+// rejected by NewFunction.
+type File struct {
+	FieldName   string
+	FileName    string
+	ContentType string
+	Content     []byte
+}
+
+// MultipartRequestParser returns a RequestParser for multipart/form-data requests. It uses gorilla/schema to map text
+// fields to a struct, and populates fields tagged with "file" with the uploaded files. Base64 encoded bodies are
+// decoded. If maxBodySize or maxFileSize are positive, larger (decoded) bodies or files are rejected.
+func MultipartRequestParser(maxBodySize, maxFileSize int64) RequestParser {
+	return func(ctx context.Context, reqType reflect.Type, in *events.APIGatewayProxyRequest) (interface{}, error) { // RequestParser
+		if reqType == noRequestBody {
+			if in.Body != "" {
+				return nil, errors.Errorf("unexpected Body", unexpectedBody)
+			}
+
+			return nil, nil
+		}
+
+		body, err := getBody(in)
+		if err != nil {
+			return nil, err
+		}
+
+		if maxBodySize > 0 && int64(len(body)) > maxBodySize {
+			return nil, errors.Errorf("Body too large: expected at most %v bytes, got %v", maxBodySize, len(body), bodyTooLarge)
+		}
+
+		_, params, err := mime.ParseMediaType(GetHeaders(ctx).Get("Content-Type"))
+		if err != nil {
+			return nil, errors.Wrap(err, errors.Prefix("invalid Content-Type"), invalidContentType)
+		}
+
+		if params["boundary"] == "" {
+			return nil, errors.Errorf("missing multipart boundary", invalidContentType)
+		}
+
+		values, files, err := readMultipart(multipart.NewReader(bytes.NewReader(body), params["boundary"]), maxFileSize)
+		if err != nil {
+			return nil, err
+		}
+
+		req := reflect.New(reqType).Interface()
+		if err := defaultDecoder.Decode(req, values); err != nil {
+			return nil, errors.Wrap(err, errors.Prefix("invalid Body"), invalidBody)
+		}
+
+		if err := bindFiles(reflect.ValueOf(req).Elem(), files); err != nil {
+			return nil, err
+		}
+
+		if hasBindings(reqType) {
+			if err := bindParameters(in, req); err != nil {
+				return nil, err
+			}
+		}
+
+		return req, nil
+	}
+}
+
+func readMultipart(r *multipart.Reader, maxFileSize int64) (url.Values, map[string][]*File, error) {
+	values := url.Values{}
+	files := make(map[string][]*File)
+
+	for {
+		part, err := r.NextPart()
+		if err == io.EOF {
+			return values, files, nil
+		}
+		if err != nil {
+			return nil, nil, errors.Wrap(err, errors.Prefix("invalid Body"), invalidBody)
+		}
+
+		if part.FileName() == "" {
+			content, err := ioutil.ReadAll(part)
+			if err != nil {
+				return nil, nil, errors.Wrap(err, errors.Prefix("invalid Body"), invalidBody)
+			}
+			values.Add(part.FormName(), string(content))
+			continue
+		}
+
+		var reader io.Reader = part
+		if maxFileSize > 0 {
+			reader = io.LimitReader(part, maxFileSize+1)
+		}
+
+		content, err := ioutil.ReadAll(reader)
+		if err != nil {
+			return nil, nil, errors.Wrap(err, errors.Prefix("invalid Body"), invalidBody)
+		}
+
+		if maxFileSize > 0 && int64(len(content)) > maxFileSize {
+			return nil, nil, errors.Errorf("file '%v' too large: expected at most %v bytes", part.FileName(), maxFileSize, bodyTooLarge)
+		}
+
+		files[part.FormName()] = append(files[part.FormName()], &File{
+			FieldName:   part.FormName(),
+			FileName:    part.FileName(),
+			ContentType: part.Header.Get("Content-Type"),
+			Content:     content,
+		})
+	}
+}
+
+// checkFileFields returns an error if a field of the given struct type, or of its embedded structs, is tagged with "file"
+// but is unexported or not of type *File or []*File.
+func checkFileFields(t reflect.Type) error {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+
+		if field.Anonymous && field.Type.Kind() == reflect.Struct {
+			if err := checkFileFields(field.Type); err != nil {
+				return err
+			}
+			continue
+		}
+
+		if _, ok := field.Tag.Lookup(fileTag); !ok {
+			continue
+		}
+
+		if field.PkgPath != "" {
+			return errors.Errorf("file field '%v' must be exported", field.Name)
+		}
+
+		if field.Type != fileType && field.Type != fileSliceType {
+			return errors.Errorf("invalid type for file field '%v': expected '*mbd.File' or '[]*mbd.File', got '%v'", field.Name, field.Type)
+		}
+	}
+
+	return nil
+}
+
+func bindFiles(v reflect.Value, files map[string][]*File) error {
+	for i := 0; i < v.NumField(); i++ {
+		field := v.Type().Field(i)
+
+		if field.Anonymous && field.Type.Kind() == reflect.Struct {
+			if err := bindFiles(v.Field(i), files); err != nil {
+				return err
+			}
+			continue
+		}
+
+		if field.PkgPath != "" {
+			continue
+		}
+
+		name, ok := field.Tag.Lookup(fileTag)
+		if !ok || len(files[name]) == 0 {
+			continue
+		}
+
+		switch field.Type {
+		case fileType:
+			v.Field(i).Set(reflect.ValueOf(files[name][len(files[name])-1]))
+		case fileSliceType:
+			v.Field(i).Set(reflect.ValueOf(files[name]))
+		default:
+			return errors.Errorf("invalid type for file field '%v': expected '*mbd.File' or '[]*mbd.File', got '%v'", field.Name, field.Type)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,154 @@
+package mbd
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"mime/multipart"
+	"net/http"
+	"net/textproto"
+	"reflect"
+	"testing"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/ibrt/errors"
+	"github.com/stretchr/testify/require"
+)
+
+type multipartTestRequest struct {
+	Value     string  `schema:"value"`
+	Avatar    *File   `schema:"-" file:"avatar"`
+	Documents []*File `schema:"-" file:"documents"`
+}
+
+func newMultipartTestRequest(t *testing.T, base64Encoded bool) *events.APIGatewayProxyRequest {
+	buf := &bytes.Buffer{}
+	w := multipart.NewWriter(buf)
+	require.NoError(t, w.WriteField("value", "testValue"))
+
+	for _, f := range []*File{
+		{FieldName: "avatar", FileName: "avatar.png", ContentType: "image/png", Content: []byte{0, 1, 2}},
+		{FieldName: "documents", FileName: "a.txt", ContentType: "text/plain", Content: []byte("a")},
+		{FieldName: "documents", FileName: "b.txt", ContentType: "text/plain", Content: []byte("b")},
+	} {
+		part, err := w.CreatePart(textproto.MIMEHeader{
+			"Content-Disposition": {`form-data; name="` + f.FieldName + `"; filename="` + f.FileName + `"`},
+			"Content-Type":        {f.ContentType},
+		})
+		require.NoError(t, err)
+		_, err = part.Write(f.Content)
+		require.NoError(t, err)
+	}
+	require.NoError(t, w.Close())
+
+	in := &events.APIGatewayProxyRequest{
+		Headers: map[string]string{"Content-Type": w.FormDataContentType()},
+		Body:    buf.String(),
+	}
+
+	if base64Encoded {
+		in.IsBase64Encoded = true
+		in.Body = base64.StdEncoding.EncodeToString(buf.Bytes())
+	}
+
+	return in
+}
+
+func TestMultipartRequestParser(t *testing.T) {
+	for _, base64Encoded := range []bool{false, true} {
+		in := newMultipartTestRequest(t, base64Encoded)
+		req, err := MultipartRequestParser(0, 0)(populateContext(context.Background(), false, in), reflect.TypeOf(multipartTestRequest{}), in)
+		require.NoError(t, err)
+		require.Equal(t, &multipartTestRequest{
+			Value:  "testValue",
+			Avatar: &File{FieldName: "avatar", FileName: "avatar.png", ContentType: "image/png", Content: []byte{0, 1, 2}},
+			Documents: []*File{
+				{FieldName: "documents", FileName: "a.txt", ContentType: "text/plain", Content: []byte("a")},
+				{FieldName: "documents", FileName: "b.txt", ContentType: "text/plain", Content: []byte("b")},
+			},
+		}, req)
+	}
+}
+
+func TestMultipartRequestParser_UnexpectedBody(t *testing.T) {
+	_, err := MultipartRequestParser(0, 0)(context.Background(), noRequestBody, &events.APIGatewayProxyRequest{Body: "unexpected"})
+	require.EqualError(t, err, "unexpected Body")
+	require.Equal(t, http.StatusBadRequest, errors.GetHTTPStatus(err))
+	require.Equal(t, "unexpected-body", errors.GetPublicMessage(err))
+}
+
+func TestMultipartRequestParser_TooLarge(t *testing.T) {
+	in := newMultipartTestRequest(t, false)
+	_, err := MultipartRequestParser(10, 0)(populateContext(context.Background(), false, in), reflect.TypeOf(multipartTestRequest{}), in)
+	require.Error(t, err)
+	require.Equal(t, http.StatusRequestEntityTooLarge, errors.GetHTTPStatus(err))
+	require.Equal(t, "body-too-large", errors.GetPublicMessage(err))
+
+	_, err = MultipartRequestParser(0, 2)(populateContext(context.Background(), false, in), reflect.TypeOf(multipartTestRequest{}), in)
+	require.EqualError(t, err, "file 'avatar.png' too large: expected at most 2 bytes")
+	require.Equal(t, http.StatusRequestEntityTooLarge, errors.GetHTTPStatus(err))
+	require.Equal(t, "body-too-large", errors.GetPublicMessage(err))
+}
+
+func TestMultipartRequestParser_InvalidContentType(t *testing.T) {
+	in := newMultipartTestRequest(t, false)
+	in.Headers["Content-Type"] = "multipart/form-data"
+	_, err := MultipartRequestParser(0, 0)(populateContext(context.Background(), false, in), reflect.TypeOf(multipartTestRequest{}), in)
+	require.EqualError(t, err, "missing multipart boundary")
+	require.Equal(t, http.StatusUnsupportedMediaType, errors.GetHTTPStatus(err))
+	require.Equal(t, "invalid-content-type", errors.GetPublicMessage(err))
+}
+
+func TestMultipartRequestParser_InvalidBody(t *testing.T) {
+	in := newMultipartTestRequest(t, false)
+	in.IsBase64Encoded = true
+	_, err := MultipartRequestParser(0, 0)(populateContext(context.Background(), false, in), reflect.TypeOf(multipartTestRequest{}), in)
+	require.Error(t, err)
+	require.Equal(t, http.StatusBadRequest, errors.GetHTTPStatus(err))
+	require.Equal(t, "invalid-body", errors.GetPublicMessage(err))
+}
+
+func TestMultipartRequestParser_InvalidFileField(t *testing.T) {
+	in := newMultipartTestRequest(t, false)
+	_, err := MultipartRequestParser(0, 0)(populateContext(context.Background(), false, in), reflect.TypeOf(struct {
+		Avatar []byte `schema:"-" file:"avatar"`
+	}{}), in)
+	require.EqualError(t, err, "invalid type for file field 'Avatar': expected '*mbd.File' or '[]*mbd.File', got '[]uint8'")
+	require.Equal(t, 0, errors.GetHTTPStatus(err))
+}
+
+type multipartTestEmbeddedRequest struct {
+	multipartTestRequest
+	other *File `file:"avatar"`
+}
+
+func TestMultipartRequestParser_EmbeddedFileFields(t *testing.T) {
+	in := newMultipartTestRequest(t, false)
+	req, err := MultipartRequestParser(0, 0)(populateContext(context.Background(), false, in), reflect.TypeOf(multipartTestEmbeddedRequest{}), in)
+	require.NoError(t, err)
+	require.Equal(t, "testValue", req.(*multipartTestEmbeddedRequest).Value)
+	require.Equal(t, "avatar.png", req.(*multipartTestEmbeddedRequest).Avatar.FileName)
+	require.Len(t, req.(*multipartTestEmbeddedRequest).Documents, 2)
+	require.Nil(t, req.(*multipartTestEmbeddedRequest).other)
+}
+
+func TestCheckFileFields(t *testing.T) {
+	require.NoError(t, checkFileFields(reflect.TypeOf(multipartTestRequest{})))
+
+	require.EqualError(t, checkFileFields(reflect.TypeOf(multipartTestEmbeddedRequest{})),
+		"file field 'other' must be exported")
+
+	require.EqualError(t, checkFileFields(reflect.TypeOf(struct {
+		multipartTestRequest
+		Embedded struct {
+			Avatar *File `file:"avatar"`
+		}
+		Other File `file:"other"`
+	}{})), "invalid type for file field 'Other': expected '*mbd.File' or '[]*mbd.File', got 'mbd.File'")
+
+	require.Panics(t, func() {
+		NewFunction(struct {
+			Avatar []byte `file:"avatar"`
+		}{}, func(ctx context.Context, req interface{}) (interface{}, error) { return nil, nil })
+	})
+}
@@ -1,8 +1,11 @@
 package mbd
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
+	"io"
+	"mime"
 	"net/url"
 	"reflect"
 	"strings"
@@ -15,19 +18,18 @@ import (
 
 var (
 	defaultDecoder = schema.NewDecoder()
+	rawRequestType = reflect.TypeOf(RawRequest{})
 )
 
 func init() {
 	defaultDecoder.IgnoreUnknownKeys(true)
 }
 
-// JSONRequestParser returns a RequestParser for JSON requests.
+// JSONRequestParser returns a RequestParser for JSON requests. Base64 encoded bodies are decoded. Fields tagged with
+// "path", "query" or "header" are populated from the corresponding request parameters (see FormRequestParser): they
+// should usually be tagged `json:"-"`. If the request struct has any such field, an empty body is accepted.
 func JSONRequestParser() RequestParser {
 	return func(_ context.Context, reqType reflect.Type, in *events.APIGatewayProxyRequest) (interface{}, error) { // RequestParser
-		if in.IsBase64Encoded {
-			return nil, errors.Errorf("invalid IsBase64Encoded: expected 'false', got 'true'", invalidBody)
-		}
-
 		if reqType == noRequestBody {
 			if in.Body != "" {
 				return nil, errors.Errorf("unexpected Body", unexpectedBody)
@@ -36,14 +38,28 @@ func JSONRequestParser() RequestParser {
 			return nil, nil
 		}
 
+		body, err := getBody(in)
+		if err != nil {
+			return nil, err
+		}
+
 		req := reflect.New(reqType).Interface()
+		bindings := hasBindings(reqType)
 
-		dec := json.NewDecoder(strings.NewReader(in.Body))
-		dec.DisallowUnknownFields()
-		dec.UseNumber()
+		if len(body) > 0 || !bindings {
+			dec := json.NewDecoder(bytes.NewReader(body))
+			dec.DisallowUnknownFields()
+			dec.UseNumber()
 
-		if err := dec.Decode(req); err != nil {
-			return nil, errors.Wrap(err, errors.Prefix("invalid Body"), invalidBody)
+			if err := dec.Decode(req); err != nil {
+				return nil, errors.Wrap(err, errors.Prefix("invalid Body"), invalidBody)
+			}
+		}
+
+		if bindings {
+			if err := bindParameters(in, req); err != nil {
+				return nil, err
+			}
 		}
 
 		return req, nil
@@ -51,12 +67,14 @@ func JSONRequestParser() RequestParser {
 }
 
 // FormRequestParser returns a RequestParser for form encoded requests. It uses gorilla/schema to map values to a struct.
+// Base64 encoded bodies are decoded.
+//
+// Fields tagged with `path:"name"`, `query:"name"` or `header:"name"` are populated from the corresponding request
+// parameters, after the body has been parsed. Supported field types are strings, bools, numbers, time.Time (RFC 3339),
+// time.Duration, encoding.TextUnmarshaler implementations, and pointers to them. Slices collect all the values of
+// multi-value query string parameters and headers.
 func FormRequestParser() RequestParser {
 	return func(_ context.Context, reqType reflect.Type, in *events.APIGatewayProxyRequest) (interface{}, error) { // RequestParser
-		if in.IsBase64Encoded {
-			return nil, errors.Errorf("invalid IsBase64Encoded: expected 'false', got 'true'", invalidBody)
-		}
-
 		if reqType == noRequestBody {
 			if in.Body != "" {
 				return nil, errors.Errorf("unexpected Body", unexpectedBody)
@@ -65,7 +83,12 @@ func FormRequestParser() RequestParser {
 			return nil, nil
 		}
 
-		q, err := url.ParseQuery(in.Body)
+		body, err := getBody(in)
+		if err != nil {
+			return nil, err
+		}
+
+		q, err := url.ParseQuery(string(body))
 		if err != nil {
 			return nil, errors.Wrap(err, errors.Prefix("invalid Body"), invalidBody)
 		}
@@ -75,6 +98,98 @@ func FormRequestParser() RequestParser {
 			return nil, errors.Wrap(err, errors.Prefix("invalid Body"), invalidBody)
 		}
 
+		if hasBindings(reqType) {
+			if err := bindParameters(in, req); err != nil {
+				return nil, err
+			}
+		}
+
 		return req, nil
 	}
 }
+
+// RawRequest is a request that exposes the raw (base64 decoded) body, for use with RawRequestParser.
+type RawRequest struct {
+	ContentType string
+	Body        []byte
+}
+
+// Reader returns an io.Reader for the request body.
+func (r *RawRequest) Reader() io.Reader {
+	return bytes.NewReader(r.Body)
+}
+
+// RawRequestParser returns a RequestParser that delivers the request body as it is, decoding base64 encoded bodies.
+// The request template must be RawRequest{}. It is useful for binary payloads such as images, PDFs or protobuf.
+func RawRequestParser() RequestParser {
+	return func(ctx context.Context, reqType reflect.Type, in *events.APIGatewayProxyRequest) (interface{}, error) { // RequestParser
+		if reqType == noRequestBody {
+			if in.Body != "" {
+				return nil, errors.Errorf("unexpected Body", unexpectedBody)
+			}
+
+			return nil, nil
+		}
+
+		if reqType != rawRequestType {
+			return nil, errors.Errorf("invalid request type: expected '%v', got '%v'", rawRequestType, reqType)
+		}
+
+		body, err := getBody(in)
+		if err != nil {
+			return nil, err
+		}
+
+		return &RawRequest{
+			ContentType: GetHeaders(ctx).Get("Content-Type"),
+			Body:        body,
+		}, nil
+	}
+}
+
+// NegotiatingRequestParser returns a RequestParser that dispatches requests to one of the given RequestParser(s), keyed
+// by media type (e.g. "application/json"), based on the request Content-Type header. If parsers is empty, JSON and form
+// parsers are registered. Requests with an unknown or missing Content-Type are rejected with 415, except requests with
+// an empty body and no Content-Type, which are dispatched to the "application/json" parser if registered, or accepted
+// if the request type has no body.
+func NegotiatingRequestParser(parsers map[string]RequestParser) RequestParser {
+	if len(parsers) == 0 {
+		parsers = map[string]RequestParser{
+			"application/json":                  JSONRequestParser(),
+			"application/x-www-form-urlencoded": FormRequestParser(),
+		}
+	}
+
+	lowercaseParsers := make(map[string]RequestParser, len(parsers))
+	for mediaType, parser := range parsers {
+		lowercaseParsers[strings.ToLower(mediaType)] = parser
+	}
+
+	return func(ctx context.Context, reqType reflect.Type, in *events.APIGatewayProxyRequest) (interface{}, error) { // RequestParser
+		contentType := GetHeaders(ctx).Get("Content-Type")
+
+		if contentType == "" {
+			if in.Body == "" {
+				if parser, ok := lowercaseParsers["application/json"]; ok {
+					return parser(ctx, reqType, in)
+				}
+				if reqType == noRequestBody {
+					return nil, nil
+				}
+			}
+			return nil, errors.Errorf("missing Content-Type", invalidContentType)
+		}
+
+		mediaType, _, err := mime.ParseMediaType(contentType)
+		if err != nil {
+			return nil, errors.Wrap(err, errors.Prefix("invalid Content-Type"), invalidContentType)
+		}
+
+		parser, ok := lowercaseParsers[mediaType]
+		if !ok {
+			return nil, errors.Errorf("unsupported Content-Type '%v'", mediaType, invalidContentType)
+		}
+
+		return parser(ctx, reqType, in)
+	}
+}
@@ -2,6 +2,8 @@ package mbd
 
 import (
 	"context"
+	"encoding/base64"
+	"io/ioutil"
 	"net/http"
 	"reflect"
 	"testing"
@@ -11,9 +13,18 @@ import (
 	"github.com/stretchr/testify/require"
 )
 
+func TestParseJSONRequest_Base64Body(t *testing.T) {
+	req, err := JSONRequestParser()(context.Background(), reflect.TypeOf(struct{ Value string }{}), &events.APIGatewayProxyRequest{
+		IsBase64Encoded: true,
+		Body:            base64.StdEncoding.EncodeToString([]byte(`{"Value":"v"}`)),
+	})
+	require.NoError(t, err)
+	require.Equal(t, &struct{ Value string }{Value: "v"}, req)
+}
+
 func TestParseJSONRequest_InvalidBody(t *testing.T) {
-	_, err := JSONRequestParser()(context.Background(), reflect.TypeOf(struct{}{}), &events.APIGatewayProxyRequest{IsBase64Encoded: true})
-	require.EqualError(t, err, "invalid IsBase64Encoded: expected 'false', got 'true'")
+	_, err := JSONRequestParser()(context.Background(), reflect.TypeOf(struct{}{}), &events.APIGatewayProxyRequest{IsBase64Encoded: true, Body: "-"})
+	require.EqualError(t, err, "invalid base64 Body: illegal base64 data at input byte 0")
 	require.Equal(t, http.StatusBadRequest, errors.GetHTTPStatus(err))
 	require.Equal(t, "invalid-body", errors.GetPublicMessage(err))
 }
@@ -25,9 +36,18 @@ func TestParseJSONRequest_UnexpectedBody(t *testing.T) {
 	require.Equal(t, "unexpected-body", errors.GetPublicMessage(err))
 }
 
+func TestParseFormRequest_Base64Body(t *testing.T) {
+	req, err := FormRequestParser()(context.Background(), reflect.TypeOf(struct{ Value string }{}), &events.APIGatewayProxyRequest{
+		IsBase64Encoded: true,
+		Body:            base64.StdEncoding.EncodeToString([]byte("Value=v")),
+	})
+	require.NoError(t, err)
+	require.Equal(t, &struct{ Value string }{Value: "v"}, req)
+}
+
 func TestParseFormRequest_InvalidBody(t *testing.T) {
-	_, err := FormRequestParser()(context.Background(), reflect.TypeOf(struct{}{}), &events.APIGatewayProxyRequest{IsBase64Encoded: true})
-	require.EqualError(t, err, "invalid IsBase64Encoded: expected 'false', got 'true'")
+	_, err := FormRequestParser()(context.Background(), reflect.TypeOf(struct{}{}), &events.APIGatewayProxyRequest{IsBase64Encoded: true, Body: "-"})
+	require.EqualError(t, err, "invalid base64 Body: illegal base64 data at input byte 0")
 	require.Equal(t, http.StatusBadRequest, errors.GetHTTPStatus(err))
 	require.Equal(t, "invalid-body", errors.GetPublicMessage(err))
 }
@@ -38,3 +58,112 @@ func TestParseFormRequest_UnexpectedBody(t *testing.T) {
 	require.Equal(t, http.StatusBadRequest, errors.GetHTTPStatus(err))
 	require.Equal(t, "unexpected-body", errors.GetPublicMessage(err))
 }
+
+func TestRawRequestParser(t *testing.T) {
+	in := &events.APIGatewayProxyRequest{
+		Headers:         map[string]string{"Content-Type": "application/octet-stream"},
+		IsBase64Encoded: true,
+		Body:            base64.StdEncoding.EncodeToString([]byte{0, 1, 2}),
+	}
+	req, err := RawRequestParser()(populateContext(context.Background(), false, in), reflect.TypeOf(RawRequest{}), in)
+	require.NoError(t, err)
+	require.Equal(t, &RawRequest{ContentType: "application/octet-stream", Body: []byte{0, 1, 2}}, req)
+
+	buf, err := ioutil.ReadAll(req.(*RawRequest).Reader())
+	require.NoError(t, err)
+	require.Equal(t, []byte{0, 1, 2}, buf)
+
+	in = &events.APIGatewayProxyRequest{Body: "text"}
+	req, err = RawRequestParser()(populateContext(context.Background(), false, in), reflect.TypeOf(RawRequest{}), in)
+	require.NoError(t, err)
+	require.Equal(t, &RawRequest{Body: []byte("text")}, req)
+}
+
+func TestRawRequestParser_Errors(t *testing.T) {
+	_, err := RawRequestParser()(context.Background(), noRequestBody, &events.APIGatewayProxyRequest{Body: "unexpected"})
+	require.EqualError(t, err, "unexpected Body")
+	require.Equal(t, "unexpected-body", errors.GetPublicMessage(err))
+
+	_, err = RawRequestParser()(context.Background(), reflect.TypeOf(struct{}{}), &events.APIGatewayProxyRequest{})
+	require.EqualError(t, err, "invalid request type: expected 'mbd.RawRequest', got 'struct {}'")
+	require.Equal(t, 0, errors.GetHTTPStatus(err))
+
+	_, err = RawRequestParser()(context.Background(), reflect.TypeOf(RawRequest{}), &events.APIGatewayProxyRequest{IsBase64Encoded: true, Body: "-"})
+	require.EqualError(t, err, "invalid base64 Body: illegal base64 data at input byte 0")
+	require.Equal(t, "invalid-body", errors.GetPublicMessage(err))
+}
+
+func TestNegotiatingRequestParser(t *testing.T) {
+	reqType := reflect.TypeOf(struct {
+		Value string `json:"value" schema:"value"`
+	}{})
+
+	in := &events.APIGatewayProxyRequest{
+		Headers: map[string]string{"Content-Type": "application/json; charset=utf-8"},
+		Body:    `{"value":"json"}`,
+	}
+	req, err := NegotiatingRequestParser(nil)(populateContext(context.Background(), false, in), reqType, in)
+	require.NoError(t, err)
+	require.Equal(t, "json", reflect.ValueOf(req).Elem().Field(0).String())
+
+	in = &events.APIGatewayProxyRequest{
+		Headers: map[string]string{"content-type": "Application/X-WWW-Form-Urlencoded"},
+		Body:    "value=form",
+	}
+	req, err = NegotiatingRequestParser(nil)(populateContext(context.Background(), false, in), reqType, in)
+	require.NoError(t, err)
+	require.Equal(t, "form", reflect.ValueOf(req).Elem().Field(0).String())
+
+	in = &events.APIGatewayProxyRequest{}
+	req, err = NegotiatingRequestParser(nil)(populateContext(context.Background(), false, in), noRequestBody, in)
+	require.NoError(t, err)
+	require.Nil(t, req)
+}
+
+func TestNegotiatingRequestParser_Custom(t *testing.T) {
+	parser := NegotiatingRequestParser(map[string]RequestParser{
+		"Text/Plain": func(_ context.Context, _ reflect.Type, in *events.APIGatewayProxyRequest) (interface{}, error) {
+			return in.Body, nil
+		},
+	})
+
+	in := &events.APIGatewayProxyRequest{
+		Headers: map[string]string{"Content-Type": "text/plain"},
+		Body:    "text",
+	}
+	req, err := parser(populateContext(context.Background(), false, in), noRequestBody, in)
+	require.NoError(t, err)
+	require.Equal(t, "text", req)
+
+	in = &events.APIGatewayProxyRequest{
+		Headers: map[string]string{"Content-Type": "application/json"},
+		Body:    "{}",
+	}
+	_, err = parser(populateContext(context.Background(), false, in), noRequestBody, in)
+	require.EqualError(t, err, "unsupported Content-Type 'application/json'")
+	require.Equal(t, http.StatusUnsupportedMediaType, errors.GetHTTPStatus(err))
+	require.Equal(t, "invalid-content-type", errors.GetPublicMessage(err))
+
+	in = &events.APIGatewayProxyRequest{}
+	req, err = parser(populateContext(context.Background(), false, in), noRequestBody, in)
+	require.NoError(t, err)
+	require.Nil(t, req)
+
+	_, err = parser(populateContext(context.Background(), false, in), reflect.TypeOf(struct{}{}), in)
+	require.EqualError(t, err, "missing Content-Type")
+	require.Equal(t, http.StatusUnsupportedMediaType, errors.GetHTTPStatus(err))
+}
+
+func TestNegotiatingRequestParser_InvalidContentType(t *testing.T) {
+	in := &events.APIGatewayProxyRequest{Body: "{}"}
+	_, err := NegotiatingRequestParser(nil)(populateContext(context.Background(), false, in), noRequestBody, in)
+	require.EqualError(t, err, "missing Content-Type")
+	require.Equal(t, http.StatusUnsupportedMediaType, errors.GetHTTPStatus(err))
+	require.Equal(t, "invalid-content-type", errors.GetPublicMessage(err))
+
+	in = &events.APIGatewayProxyRequest{Headers: map[string]string{"Content-Type": "/"}, Body: "{}"}
+	_, err = NegotiatingRequestParser(nil)(populateContext(context.Background(), false, in), noRequestBody, in)
+	require.EqualError(t, err, "invalid Content-Type: mime: no media type")
+	require.Equal(t, http.StatusUnsupportedMediaType, errors.GetHTTPStatus(err))
+	require.Equal(t, "invalid-content-type", errors.GetPublicMessage(err))
+}
@@ -0,0 +1,175 @@
+package mbd
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/ibrt/errors"
+)
+
+// MethodARN describes the ARN of an API Gateway method, as received by custom authorizers, e.g.
+// "arn:aws:execute-api:us-east-1:123456789012:abcdef1234/prod/GET/users/1".
+type MethodARN struct {
+	Partition string
+	Region    string
+	AccountID string
+	APIID     string
+	Stage     string
+	Method    string
+	Resource  string // without leading "/"
+}
+
+// ParseMethodARN parses an API Gateway method ARN.
+func ParseMethodARN(arn string) (*MethodARN, error) {
+	parts := strings.SplitN(arn, ":", 6)
+	if len(parts) != 6 || parts[0] != "arn" || parts[2] != "execute-api" {
+		return nil, errors.Errorf("invalid method ARN '%v'", arn)
+	}
+
+	path := strings.SplitN(parts[5], "/", 4)
+	if len(path) < 3 {
+		return nil, errors.Errorf("invalid method ARN '%v'", arn)
+	}
+
+	methodARN := &MethodARN{
+		Partition: parts[1],
+		Region:    parts[3],
+		AccountID: parts[4],
+		APIID:     path[0],
+		Stage:     path[1],
+		Method:    path[2],
+	}
+
+	if len(path) == 4 {
+		methodARN.Resource = path[3]
+	}
+
+	return methodARN, nil
+}
+
+// String returns the ARN.
+func (a *MethodARN) String() string {
+	return a.WithMethodAndResource(a.Method, a.Resource)
+}
+
+// WithMethodAndResource returns the ARN of another method of the same API and stage. Both method and resource can
+// contain "*" wildcards, and resource can start with "/".
+func (a *MethodARN) WithMethodAndResource(method, resource string) string {
+	return fmt.Sprintf("arn:%v:execute-api:%v:%v:%v/%v/%v/%v",
+		a.Partition, a.Region, a.AccountID, a.APIID, a.Stage, method, strings.TrimPrefix(resource, "/"))
+}
+
+// Policy describes the result of a custom authorizer: a principal ID, the methods it can or cannot invoke, and context
+// values made available to the integration (see GetRequestContext().Authorizer). Methods are resolved against the API
+// and stage of the incoming method ARN. A Policy without statements denies everything.
+type Policy struct {
+	principalID        string
+	statements         []*policyStatement
+	context            map[string]interface{}
+	usageIdentifierKey string
+}
+
+type policyStatement struct {
+	effect   string
+	method   string
+	resource string
+}
+
+// Policy effects.
+const (
+	PolicyEffectAllow = "Allow"
+	PolicyEffectDeny  = "Deny"
+)
+
+// NewPolicy initializes a new Policy for the given principal ID.
+func NewPolicy(principalID string) *Policy {
+	return &Policy{
+		principalID: principalID,
+		statements:  make([]*policyStatement, 0),
+		context:     make(map[string]interface{}),
+	}
+}
+
+// Allow allows invoking the given method and resource, e.g. ("GET", "/users/*"). Both can be "*".
+func (p *Policy) Allow(method, resource string) *Policy {
+	return p.addStatement(PolicyEffectAllow, method, resource)
+}
+
+// Deny denies invoking the given method and resource, e.g. ("DELETE", "*"). Both can be "*". Deny takes precedence
+// over Allow.
+func (p *Policy) Deny(method, resource string) *Policy {
+	return p.addStatement(PolicyEffectDeny, method, resource)
+}
+
+// AllowAll allows invoking all methods of the API and stage. It is recommended when authorizer caching is enabled, as
+// the cached Policy is reused for other methods.
+func (p *Policy) AllowAll() *Policy {
+	return p.Allow("*", "*")
+}
+
+// DenyAll denies invoking all methods of the API and stage.
+func (p *Policy) DenyAll() *Policy {
+	return p.Deny("*", "*")
+}
+
+// SetContextValue sets a context value, which must be a string, number or boolean.
+func (p *Policy) SetContextValue(k string, v interface{}) *Policy {
+	switch v.(type) {
+	case string, bool, int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64, float32, float64:
+	default:
+		errors.MustErrorf("unsupported context value type '%T'", v)
+	}
+
+	p.context[k] = v
+	return p
+}
+
+// SetUsageIdentifierKey sets the API key used for usage plans.
+func (p *Policy) SetUsageIdentifierKey(usageIdentifierKey string) *Policy {
+	p.usageIdentifierKey = usageIdentifierKey
+	return p
+}
+
+func (p *Policy) addStatement(effect, method, resource string) *Policy {
+	errors.Assert(method != "", "method must not be empty")
+	errors.Assert(resource != "", "resource must not be empty")
+
+	p.statements = append(p.statements, &policyStatement{
+		effect:   effect,
+		method:   strings.ToUpper(method),
+		resource: resource,
+	})
+	return p
+}
+
+// toResponse renders the Policy, resolving methods against the given method ARN.
+func (p *Policy) toResponse(methodARN *MethodARN) events.APIGatewayCustomAuthorizerResponse {
+	statements := p.statements
+	if len(statements) == 0 {
+		statements = []*policyStatement{{effect: PolicyEffectDeny, method: "*", resource: "*"}}
+	}
+
+	resp := events.APIGatewayCustomAuthorizerResponse{
+		PrincipalID: p.principalID,
+		PolicyDocument: events.APIGatewayCustomAuthorizerPolicy{
+			Version:   "2012-10-17",
+			Statement: make([]events.IAMPolicyStatement, len(statements)),
+		},
+		UsageIdentifierKey: p.usageIdentifierKey,
+	}
+
+	for i, statement := range statements {
+		resp.PolicyDocument.Statement[i] = events.IAMPolicyStatement{
+			Action:   []string{"execute-api:Invoke"},
+			Effect:   statement.effect,
+			Resource: []string{methodARN.WithMethodAndResource(statement.method, statement.resource)},
+		}
+	}
+
+	if len(p.context) > 0 {
+		resp.Context = p.context
+	}
+
+	return resp
+}
@@ -0,0 +1,79 @@
+package mbd
+
+import (
+	"testing"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseMethodARN(t *testing.T) {
+	methodARN, err := ParseMethodARN("arn:aws:execute-api:us-east-1:123456789012:abcdef1234/prod/GET/users/1")
+	require.NoError(t, err)
+	require.Equal(t, &MethodARN{
+		Partition: "aws",
+		Region:    "us-east-1",
+		AccountID: "123456789012",
+		APIID:     "abcdef1234",
+		Stage:     "prod",
+		Method:    "GET",
+		Resource:  "users/1",
+	}, methodARN)
+	require.Equal(t, "arn:aws:execute-api:us-east-1:123456789012:abcdef1234/prod/GET/users/1", methodARN.String())
+	require.Equal(t, "arn:aws:execute-api:us-east-1:123456789012:abcdef1234/prod/*/users/*", methodARN.WithMethodAndResource("*", "/users/*"))
+
+	methodARN, err = ParseMethodARN("arn:aws:execute-api:us-east-1:123456789012:abcdef1234/prod/GET")
+	require.NoError(t, err)
+	require.Equal(t, "", methodARN.Resource)
+
+	for _, arn := range []string{"", "arn:aws:lambda:us-east-1:123456789012:function", "arn:aws:execute-api:us-east-1:123456789012:abcdef1234/prod"} {
+		_, err = ParseMethodARN(arn)
+		require.Error(t, err, arn)
+	}
+}
+
+func TestPolicy(t *testing.T) {
+	methodARN, err := ParseMethodARN("arn:aws:execute-api:us-east-1:123456789012:abcdef1234/prod/GET/users/1")
+	require.NoError(t, err)
+
+	resp := NewPolicy("user").
+		AllowAll().
+		Deny("delete", "/users/*").
+		SetContextValue("tenantId", "tenant").
+		SetContextValue("admin", false).
+		SetContextValue("level", 2).
+		SetUsageIdentifierKey("key").
+		toResponse(methodARN)
+
+	require.Equal(t, events.APIGatewayCustomAuthorizerResponse{
+		PrincipalID: "user",
+		PolicyDocument: events.APIGatewayCustomAuthorizerPolicy{
+			Version: "2012-10-17",
+			Statement: []events.IAMPolicyStatement{
+				{
+					Action:   []string{"execute-api:Invoke"},
+					Effect:   "Allow",
+					Resource: []string{"arn:aws:execute-api:us-east-1:123456789012:abcdef1234/prod/*/*"},
+				},
+				{
+					Action:   []string{"execute-api:Invoke"},
+					Effect:   "Deny",
+					Resource: []string{"arn:aws:execute-api:us-east-1:123456789012:abcdef1234/prod/DELETE/users/*"},
+				},
+			},
+		},
+		Context:            map[string]interface{}{"tenantId": "tenant", "admin": false, "level": 2},
+		UsageIdentifierKey: "key",
+	}, resp)
+
+	resp = NewPolicy("user").toResponse(methodARN)
+	require.Equal(t, []events.IAMPolicyStatement{{
+		Action:   []string{"execute-api:Invoke"},
+		Effect:   "Deny",
+		Resource: []string{"arn:aws:execute-api:us-east-1:123456789012:abcdef1234/prod/*/*"},
+	}}, resp.PolicyDocument.Statement)
+	require.Nil(t, resp.Context)
+
+	require.Panics(t, func() { NewPolicy("user").SetContextValue("k", []string{}) })
+	require.Panics(t, func() { NewPolicy("user").Allow("", "*") })
+}
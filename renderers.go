@@ -0,0 +1,128 @@
+package mbd
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/ibrt/errors"
+)
+
+// ErrorRenderer renders an error into an API Gateway response. It receives the error, after ErrorMapper(s) have been
+// applied, and its inner errors as returned by errors.Split().
+type ErrorRenderer interface {
+	RenderError(ctx context.Context, err error, errs []error) *events.APIGatewayProxyResponse
+}
+
+// ErrorRendererFunc allows using a function as ErrorRenderer.
+type ErrorRendererFunc func(ctx context.Context, err error, errs []error) *events.APIGatewayProxyResponse
+
+// RenderError implements ErrorRenderer.
+func (f ErrorRendererFunc) RenderError(ctx context.Context, err error, errs []error) *events.APIGatewayProxyResponse {
+	return f(ctx, err, errs)
+}
+
+// ProblemResponse describes an RFC 7807 "application/problem+json" error response. The fields after Instance are
+// extension members.
+type ProblemResponse struct {
+	Type             string                `json:"type"`
+	Title            string                `json:"title"`
+	Status           int                   `json:"status"`
+	Detail           string                `json:"detail,omitempty"`
+	Instance         string                `json:"instance,omitempty"`
+	RequestID        string                `json:"requestId,omitempty"`
+	Code             string                `json:"code,omitempty"`
+	Details          ErrorDetails          `json:"details,omitempty"`
+	ValidationErrors []*ValidationError    `json:"validationErrors,omitempty"`
+	Errors           []*ErrorResponseError `json:"errors,omitempty"` // included only if debug context value is set to true
+}
+
+var (
+	defaultErrorRenderer = DefaultErrorRenderer()
+)
+
+// DefaultErrorRenderer returns an ErrorRenderer that encodes ErrorResponse using the negotiated ResponseEncoder,
+// falling back to JSON if it cannot encode it. It is the default.
+func DefaultErrorRenderer() ErrorRenderer {
+	return ErrorRendererFunc(func(ctx context.Context, err error, errs []error) *events.APIGatewayProxyResponse {
+		errResp := NewErrorResponse(ctx, err, errs)
+		out := newResponse(errResp.StatusCode)
+		if err := adaptBody(ctx, out, errResp); err != nil {
+			// fall back to the default encoder if the negotiated one cannot encode errors
+			errors.MaybeMustWrap(adaptBody(context.WithValue(ctx, responseEncoderContextKey, defaultResponseEncoder), out, errResp))
+		}
+		return out
+	})
+}
+
+// mediaTypeErrorRenderer is implemented by ErrorRenderer(s) that encode errors with their own media type, independent of
+// the negotiated ResponseEncoder. Requests that only accept that media type are not rejected with 406.
+type mediaTypeErrorRenderer interface {
+	ErrorRenderer
+	MediaType() string
+}
+
+type problemErrorRenderer struct {
+	ErrorRendererFunc
+}
+
+// MediaType implements mediaTypeErrorRenderer.
+func (*problemErrorRenderer) MediaType() string {
+	return "application/problem+json"
+}
+
+// ProblemErrorRenderer returns an ErrorRenderer that encodes errors as RFC 7807 "application/problem+json" documents.
+// The problem type is typeBaseURI followed by the public message, or "about:blank" if typeBaseURI is empty. The title
+// is the standard status text, the detail is the public message, and the instance is the request path. Requests that
+// only accept "application/problem+json" are not rejected with 406: their success responses use the first
+// ResponseEncoder.
+func ProblemErrorRenderer(typeBaseURI string) ErrorRenderer {
+	return &problemErrorRenderer{func(ctx context.Context, err error, errs []error) *events.APIGatewayProxyResponse {
+		errResp := NewErrorResponse(ctx, err, errs)
+		problemType := "about:blank"
+		if typeBaseURI != "" {
+			problemType = strings.TrimSuffix(typeBaseURI, "/") + "/" + errResp.PublicMessage
+		}
+
+		var instance string
+		if path, ok := ctx.Value(pathContextKey).(*Path); ok {
+			instance = path.Path
+		}
+
+		buf, marshalErr := json.MarshalIndent(&ProblemResponse{
+			Type:             problemType,
+			Title:            http.StatusText(errResp.StatusCode),
+			Status:           errResp.StatusCode,
+			Detail:           errResp.PublicMessage,
+			Instance:         instance,
+			RequestID:        errResp.RequestID,
+			Code:             errResp.Code,
+			Details:          errResp.Details,
+			ValidationErrors: errResp.ValidationErrors,
+			Errors:           errResp.Errors,
+		}, "", "  ")
+		errors.MaybeMustWrap(marshalErr)
+
+		out := newResponse(errResp.StatusCode)
+		out.Headers["Content-Type"] = "application/problem+json; charset=utf-8"
+		out.Body = string(buf)
+		return out
+	}}
+}
+
+// acceptsErrorMediaType returns true if the ErrorRenderer has its own media type, and the Accept header allows it.
+func acceptsErrorMediaType(accept string, errRenderer ErrorRenderer) bool {
+	if errRenderer, ok := errRenderer.(mediaTypeErrorRenderer); ok {
+		return negotiateMediaType(accept, []string{errRenderer.MediaType()}) >= 0
+	}
+	return false
+}
+
+func getErrorRenderer(ctx context.Context) ErrorRenderer {
+	if errorRenderer, ok := ctx.Value(errorRendererContextKey).(ErrorRenderer); ok {
+		return errorRenderer
+	}
+	return defaultErrorRenderer
+}
@@ -0,0 +1,118 @@
+package mbd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/ibrt/errors"
+	"github.com/stretchr/testify/require"
+)
+
+func TestProblemErrorRenderer(t *testing.T) {
+	f := NewFunction(nil, func(ctx context.Context, req interface{}) (interface{}, error) {
+		return nil, errors.Errorf("test error",
+			errors.HTTPStatusConflict,
+			errors.PublicMessage("test-error"),
+			ErrorCode("test-code"),
+			ErrorDetail("k", "v"))
+	}).SetErrorRenderer(ProblemErrorRenderer("https://example.com/problems/"))
+
+	out, err := f.Handler(context.Background(), events.APIGatewayProxyRequest{
+		Path:           "/resource",
+		RequestContext: events.APIGatewayProxyRequestContext{RequestID: "test"},
+	})
+	require.NoError(t, err)
+	require.Equal(t, http.StatusConflict, out.StatusCode)
+	require.Equal(t, "application/problem+json; charset=utf-8", out.Headers["Content-Type"])
+	require.Equal(t, "no-cache, no-store, must-revalidate", out.Headers["Cache-Control"])
+
+	problemResp := &ProblemResponse{}
+	require.NoError(t, json.Unmarshal([]byte(out.Body), problemResp))
+	require.Equal(t, &ProblemResponse{
+		Type:      "https://example.com/problems/test-error",
+		Title:     "Conflict",
+		Status:    http.StatusConflict,
+		Detail:    "test-error",
+		Instance:  "/resource",
+		RequestID: "test",
+		Code:      "test-code",
+		Details:   ErrorDetails{"k": "v"},
+	}, problemResp)
+}
+
+func TestProblemErrorRenderer_Accept(t *testing.T) {
+	f := NewFunction(nil, func(ctx context.Context, req interface{}) (interface{}, error) {
+		if GetQueryString(ctx).Get("fail") != "" {
+			return nil, errors.Errorf("test error", errors.HTTPStatusConflict)
+		}
+		return map[string]string{"k": "v"}, nil
+	}).SetErrorRenderer(ProblemErrorRenderer(""))
+
+	out, err := f.Handler(context.Background(), events.APIGatewayProxyRequest{
+		Headers:               map[string]string{"Accept": "application/problem+json"},
+		QueryStringParameters: map[string]string{"fail": "true"},
+	})
+	require.NoError(t, err)
+	require.Equal(t, http.StatusConflict, out.StatusCode)
+	require.Equal(t, "application/problem+json; charset=utf-8", out.Headers["Content-Type"])
+	require.Equal(t, "Accept", out.Headers["Vary"])
+
+	out, err = f.Handler(context.Background(), events.APIGatewayProxyRequest{
+		Headers: map[string]string{"Accept": "application/problem+json"},
+	})
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, out.StatusCode)
+	require.Equal(t, "application/json; charset=utf-8", out.Headers["Content-Type"])
+
+	out, err = f.Handler(context.Background(), events.APIGatewayProxyRequest{
+		Headers: map[string]string{"Accept": "text/html"},
+	})
+	require.NoError(t, err)
+	require.Equal(t, http.StatusNotAcceptable, out.StatusCode)
+	require.Equal(t, "application/problem+json; charset=utf-8", out.Headers["Content-Type"])
+}
+
+func TestProblemErrorRenderer_Debug(t *testing.T) {
+	f := NewFunction(nil, func(ctx context.Context, req interface{}) (interface{}, error) {
+		panic("test error")
+	}).SetDebug(true).SetErrorRenderer(ProblemErrorRenderer(""))
+
+	out, err := f.Handler(context.Background(), events.APIGatewayProxyRequest{})
+	require.NoError(t, err)
+	require.Equal(t, http.StatusInternalServerError, out.StatusCode)
+
+	problemResp := &ProblemResponse{}
+	require.NoError(t, json.Unmarshal([]byte(out.Body), problemResp))
+	require.Equal(t, "about:blank", problemResp.Type)
+	require.Equal(t, "Internal Server Error", problemResp.Title)
+	require.Equal(t, "internal-server-error", problemResp.Detail)
+	require.Empty(t, problemResp.Instance)
+	require.Len(t, problemResp.Errors, 1)
+	require.Equal(t, "test error", problemResp.Errors[0].Error)
+	require.NotEmpty(t, problemResp.Errors[0].StackTrace)
+}
+
+func TestErrorRendererFunc(t *testing.T) {
+	f := NewFunction(nil, func(ctx context.Context, req interface{}) (interface{}, error) {
+		return nil, errors.Append(errors.Errorf("first error"), errors.Errorf("second error", errors.HTTPStatusConflict))
+	}).SetErrorRenderer(ErrorRendererFunc(func(ctx context.Context, err error, errs []error) *events.APIGatewayProxyResponse {
+		errResp := NewErrorResponse(ctx, err, errs)
+		return &events.APIGatewayProxyResponse{
+			StatusCode: errResp.StatusCode,
+			Headers:    map[string]string{"Content-Type": "text/plain; charset=utf-8"},
+			Body:       fmt.Sprintf("%v: %v (%v errors)", errResp.StatusCode, errResp.PublicMessage, len(errs)),
+		}
+	}))
+
+	out, err := f.Handler(context.Background(), events.APIGatewayProxyRequest{})
+	require.NoError(t, err)
+	require.Equal(t, events.APIGatewayProxyResponse{
+		StatusCode: http.StatusConflict,
+		Headers:    map[string]string{"Content-Type": "text/plain; charset=utf-8"},
+		Body:       "409: conflict (2 errors)",
+	}, out)
+}
@@ -0,0 +1,171 @@
+package mbd
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ibrt/errors"
+)
+
+// ErrorReport describes an error reported to an ErrorReporter.
+type ErrorReport struct {
+	Timestamp     time.Time             `json:"timestamp"`
+	StatusCode    int                   `json:"statusCode"`
+	PublicMessage string                `json:"publicMessage"`
+	Panic         bool                  `json:"panic"`
+	Method        string                `json:"method"`
+	Resource      string                `json:"resource"`
+	Path          string                `json:"path"`
+	RequestID     string                `json:"requestId"`
+	Headers       map[string][]string   `json:"headers"` // sensitive headers are redacted
+	Errors        []*ErrorResponseError `json:"errors"`
+}
+
+// ErrorReporter receives reports about errors rendered by a Function, e.g. to forward them to a logging or alerting
+// system. It is invoked synchronously, before the error response is rendered.
+type ErrorReporter interface {
+	ReportError(ctx context.Context, report *ErrorReport)
+}
+
+// ErrorReporterFunc allows using a function as ErrorReporter.
+type ErrorReporterFunc func(ctx context.Context, report *ErrorReport)
+
+// ReportError implements ErrorReporter.
+func (f ErrorReporterFunc) ReportError(ctx context.Context, report *ErrorReport) {
+	f(ctx, report)
+}
+
+type errorReporting struct {
+	errReporter   ErrorReporter
+	statusClasses []int
+}
+
+var (
+	redactedHeaders = map[string]struct{}{
+		"authorization":       {},
+		"cookie":              {},
+		"proxy-authorization": {},
+		"x-api-key":           {},
+	}
+)
+
+// NewJSONErrorReporter returns an ErrorReporter that writes each ErrorReport to w as a single line of JSON, e.g. to
+// os.Stderr to have it collected by CloudWatch Logs.
+func NewJSONErrorReporter(w io.Writer) ErrorReporter {
+	m := &sync.Mutex{}
+
+	return ErrorReporterFunc(func(ctx context.Context, report *ErrorReport) {
+		buf, err := json.Marshal(report)
+		errors.MaybeMustWrap(err)
+
+		m.Lock()
+		defer m.Unlock()
+		_, err = w.Write(append(buf, '\n'))
+		errors.Ignore(err)
+	})
+}
+
+// MemoryErrorReporter is an ErrorReporter that stores reports in memory, useful for testing.
+type MemoryErrorReporter struct {
+	m       *sync.Mutex
+	reports []*ErrorReport
+}
+
+// NewMemoryErrorReporter initializes a new MemoryErrorReporter.
+func NewMemoryErrorReporter() *MemoryErrorReporter {
+	return &MemoryErrorReporter{
+		m:       &sync.Mutex{},
+		reports: make([]*ErrorReport, 0),
+	}
+}
+
+// ReportError implements ErrorReporter.
+func (r *MemoryErrorReporter) ReportError(ctx context.Context, report *ErrorReport) {
+	r.m.Lock()
+	defer r.m.Unlock()
+	r.reports = append(r.reports, report)
+}
+
+// GetReports returns the stored reports.
+func (r *MemoryErrorReporter) GetReports() []*ErrorReport {
+	r.m.Lock()
+	defer r.m.Unlock()
+	return append([]*ErrorReport{}, r.reports...)
+}
+
+// Reset discards the stored reports.
+func (r *MemoryErrorReporter) Reset() {
+	r.m.Lock()
+	defer r.m.Unlock()
+	r.reports = make([]*ErrorReport, 0)
+}
+
+// reportError invokes the ErrorReporter stored in context, if any, if the error status class matches or it is a panic.
+func reportError(ctx context.Context, err error, errs []error, isPanic bool) {
+	reporting, ok := ctx.Value(errorReportingContextKey).(*errorReporting)
+	if !ok {
+		return
+	}
+
+	statusCode := errors.GetHTTPStatusOrDefault(err, http.StatusInternalServerError)
+	if !isPanic && !matchesStatusClass(statusCode, reporting.statusClasses) {
+		return
+	}
+
+	report := &ErrorReport{
+		Timestamp:     time.Now().UTC(),
+		StatusCode:    statusCode,
+		PublicMessage: errors.GetPublicMessageOrDefault(err, getDefaultPublicMessage(statusCode)),
+		Panic:         isPanic,
+		Errors:        make([]*ErrorResponseError, len(errs)),
+	}
+
+	if path, ok := ctx.Value(pathContextKey).(*Path); ok {
+		report.Method = path.Method
+		report.Resource = path.Resource
+		report.Path = path.Path
+	}
+
+	if requestContext, ok := ctx.Value(requestContextContextKey).(*RequestContext); ok {
+		report.RequestID = requestContext.RequestID
+	}
+
+	if headers, ok := ctx.Value(headersContextKey).(*Headers); ok {
+		report.Headers = redactHeaders(headers.MapMulti())
+	}
+
+	for i, err := range errs {
+		report.Errors[i] = &ErrorResponseError{
+			Error:      err.Error(),
+			StackTrace: errors.FormatCallers(errors.GetCallersOrCurrent(err)),
+		}
+	}
+
+	reporting.errReporter.ReportError(ctx, report)
+}
+
+func matchesStatusClass(statusCode int, statusClasses []int) bool {
+	for _, statusClass := range statusClasses {
+		if statusCode/100 == statusClass {
+			return true
+		}
+	}
+	return false
+}
+
+func redactHeaders(headers map[string][]string) map[string][]string {
+	redacted := make(map[string][]string, len(headers))
+	for k, v := range headers {
+		if _, ok := redactedHeaders[strings.ToLower(k)]; ok {
+			redacted[k] = []string{"[redacted]"}
+		} else {
+			redacted[k] = v
+		}
+	}
+	return redacted
+}
@@ -0,0 +1,106 @@
+package mbd
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/ibrt/errors"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFunction_ErrorReporter(t *testing.T) {
+	var handlerErr error
+	errReporter := NewMemoryErrorReporter()
+
+	f := NewFunction(nil, func(ctx context.Context, req interface{}) (interface{}, error) {
+		if handlerErr == nil {
+			panic("test panic")
+		}
+		return nil, handlerErr
+	}).SetErrorReporter(errReporter)
+
+	in := events.APIGatewayProxyRequest{
+		Resource:   "/resource",
+		Path:       "/path",
+		HTTPMethod: http.MethodPost,
+		Headers: map[string]string{
+			"Authorization": "Bearer secret",
+			"User-Agent":    "test",
+		},
+		RequestContext: events.APIGatewayProxyRequestContext{RequestID: "test"},
+	}
+
+	handlerErr = errors.Errorf("test error", errors.HTTPStatusConflict)
+	out, err := f.Handler(context.Background(), in)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusConflict, out.StatusCode)
+	require.Empty(t, errReporter.GetReports())
+
+	handlerErr = errors.Append(errors.Errorf("first error"), errors.Errorf("second error"))
+	out, err = f.Handler(context.Background(), in)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusInternalServerError, out.StatusCode)
+	require.Len(t, errReporter.GetReports(), 1)
+
+	report := errReporter.GetReports()[0]
+	require.NotZero(t, report.Timestamp)
+	require.Equal(t, http.StatusInternalServerError, report.StatusCode)
+	require.Equal(t, "internal-server-error", report.PublicMessage)
+	require.False(t, report.Panic)
+	require.Equal(t, http.MethodPost, report.Method)
+	require.Equal(t, "/resource", report.Resource)
+	require.Equal(t, "/path", report.Path)
+	require.Equal(t, "test", report.RequestID)
+	require.Equal(t, map[string][]string{"Authorization": {"[redacted]"}, "User-Agent": {"test"}}, report.Headers)
+	require.Len(t, report.Errors, 2)
+	require.Equal(t, "first error", report.Errors[0].Error)
+	require.Equal(t, "second error", report.Errors[1].Error)
+	require.NotEmpty(t, report.Errors[1].StackTrace)
+
+	errReporter.Reset()
+	f.SetErrorReporter(errReporter, 4)
+
+	handlerErr = errors.Errorf("test error", errors.HTTPStatusConflict)
+	_, err = f.Handler(context.Background(), in)
+	require.NoError(t, err)
+	require.Len(t, errReporter.GetReports(), 1)
+	require.Equal(t, http.StatusConflict, errReporter.GetReports()[0].StatusCode)
+
+	handlerErr = nil
+	out, err = f.Handler(context.Background(), in)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusInternalServerError, out.StatusCode)
+	require.Len(t, errReporter.GetReports(), 2)
+	require.True(t, errReporter.GetReports()[1].Panic)
+	require.Equal(t, "test panic", errReporter.GetReports()[1].Errors[0].Error)
+
+	errReporter.Reset()
+	f.SetErrorReporter(nil)
+
+	_, err = f.Handler(context.Background(), in)
+	require.NoError(t, err)
+	require.Empty(t, errReporter.GetReports())
+}
+
+func TestJSONErrorReporter(t *testing.T) {
+	buf := &bytes.Buffer{}
+
+	f := NewFunction(nil, func(ctx context.Context, req interface{}) (interface{}, error) {
+		return nil, errors.Errorf("test error")
+	}).SetErrorReporter(NewJSONErrorReporter(buf))
+
+	_, err := f.Handler(context.Background(), events.APIGatewayProxyRequest{Path: "/path"})
+	require.NoError(t, err)
+	require.Equal(t, byte('\n'), buf.Bytes()[buf.Len()-1])
+
+	report := &ErrorReport{}
+	require.NoError(t, json.Unmarshal(buf.Bytes(), report))
+	require.Equal(t, http.StatusInternalServerError, report.StatusCode)
+	require.Equal(t, "/path", report.Path)
+	require.Len(t, report.Errors, 1)
+	require.Equal(t, "test error", report.Errors[0].Error)
+}
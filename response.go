@@ -0,0 +1,87 @@
+package mbd
+
+import (
+	"net/http"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+// Response allows a handler to customize the status code, headers and cookies of a successful response. The Body is
+// serialized as usual, and can also be a *SerializedResponse.
+type Response struct {
+	StatusCode        int
+	Headers           map[string]string
+	MultiValueHeaders map[string][]string
+	Cookies           []*http.Cookie
+	CachePolicy       *CachePolicy // overrides the Function CachePolicy, if not nil
+	Body              interface{}
+}
+
+// NewResponse initializes a new Response with the given status code and body.
+func NewResponse(statusCode int, body interface{}) *Response {
+	return &Response{
+		StatusCode:        statusCode,
+		Headers:           make(map[string]string),
+		MultiValueHeaders: make(map[string][]string),
+		Cookies:           make([]*http.Cookie, 0),
+		Body:              body,
+	}
+}
+
+// SetHeader sets a single-value header, replacing any existing value.
+func (r *Response) SetHeader(k, v string) *Response {
+	if r.Headers == nil {
+		r.Headers = make(map[string]string)
+	}
+	r.Headers[k] = v
+	return r
+}
+
+// AddHeader appends a value to a multi-value header.
+func (r *Response) AddHeader(k, v string) *Response {
+	if r.MultiValueHeaders == nil {
+		r.MultiValueHeaders = make(map[string][]string)
+	}
+	r.MultiValueHeaders[k] = append(r.MultiValueHeaders[k], v)
+	return r
+}
+
+// AddCookie appends a cookie, sent as Set-Cookie header.
+func (r *Response) AddCookie(cookie *http.Cookie) *Response {
+	r.Cookies = append(r.Cookies, cookie)
+	return r
+}
+
+// SetCachePolicy sets a CachePolicy for this response, overriding the Function one.
+func (r *Response) SetCachePolicy(cachePolicy *CachePolicy) *Response {
+	r.CachePolicy = cachePolicy
+	return r
+}
+
+func (r *Response) apply(out *events.APIGatewayProxyResponse) {
+	if r.StatusCode != 0 {
+		out.StatusCode = r.StatusCode
+	}
+
+	for k, v := range r.Headers {
+		out.Headers[k] = v
+	}
+
+	if len(r.MultiValueHeaders) == 0 && len(r.Cookies) == 0 {
+		return
+	}
+
+	if out.MultiValueHeaders == nil {
+		out.MultiValueHeaders = make(map[string][]string)
+	}
+
+	for k, v := range r.MultiValueHeaders {
+		out.MultiValueHeaders[k] = append(out.MultiValueHeaders[k], v...)
+	}
+
+	for _, cookie := range r.Cookies {
+		if v := cookie.String(); v != "" {
+			out.MultiValueHeaders["Set-Cookie"] = append(out.MultiValueHeaders["Set-Cookie"], v)
+		}
+	}
+}
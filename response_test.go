@@ -0,0 +1,44 @@
+package mbd
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestResponse(t *testing.T) {
+	resp := NewResponse(http.StatusCreated, map[string]string{"k": "v"}).
+		SetHeader("Location", "/resource").
+		SetHeader("Content-Type", "application/vnd.test+json").
+		AddHeader("Link", "</a>").
+		AddHeader("Link", "</b>").
+		AddCookie(&http.Cookie{Name: "c1", Value: "v1"}).
+		AddCookie(&http.Cookie{Name: "c2", Value: "v2", HttpOnly: true})
+
+	out := adaptResponse(context.Background(), http.StatusOK, resp)
+	require.Equal(t, http.StatusCreated, out.StatusCode)
+	require.Equal(t, "/resource", out.Headers["Location"])
+	require.Equal(t, "application/vnd.test+json", out.Headers["Content-Type"])
+	require.Equal(t, "no-cache, no-store, must-revalidate", out.Headers["Cache-Control"])
+	require.Equal(t, []string{"</a>", "</b>"}, out.MultiValueHeaders["Link"])
+	require.Equal(t, []string{"c1=v1", "c2=v2; HttpOnly"}, out.MultiValueHeaders["Set-Cookie"])
+	require.JSONEq(t, `{"k":"v"}`, out.Body)
+}
+
+func TestResponse_NoContent(t *testing.T) {
+	out := adaptResponse(context.Background(), http.StatusOK, &Response{StatusCode: http.StatusNoContent})
+	require.Equal(t, http.StatusNoContent, out.StatusCode)
+	require.Empty(t, out.Body)
+	require.Nil(t, out.MultiValueHeaders)
+}
+
+func TestResponse_SerializedBody(t *testing.T) {
+	out := adaptResponse(context.Background(), http.StatusOK, &Response{
+		Body: &SerializedResponse{ContentType: "text/plain", Body: "text"},
+	})
+	require.Equal(t, http.StatusOK, out.StatusCode)
+	require.Equal(t, "text/plain", out.Headers["Content-Type"])
+	require.Equal(t, "text", out.Body)
+}
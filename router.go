@@ -0,0 +1,222 @@
+package mbd
+
+import (
+	"context"
+	"sort"
+	"strings"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-lambda-go/lambda"
+	"github.com/ibrt/errors"
+)
+
+// AnyMethod can be passed to Router.AddFunction to match all HTTP methods, like the API Gateway ANY method.
+const AnyMethod = "ANY"
+
+// Router dispatches requests to one of many Function(s), allowing a single Lambda to serve multiple resources.
+//
+// Requests are first matched by API Gateway resource (e.g. "/users/{id}") and method. If no route matches the resource
+// directly (e.g. in "{proxy+}" deployments), the request path is matched against the route patterns, and the path
+// parameters extracted from it are merged into the request PathParameters. Like in API Gateway, patterns are tried from
+// the most specific: literal segments are preferred over variables, which are preferred over greedy variables.
+type Router struct {
+	routes []*route
+	debug  Debug
+}
+
+type route struct {
+	method   string
+	resource string
+	segments []string
+	function *Function
+}
+
+// NewRouter initializes a new Router.
+func NewRouter() *Router {
+	return &Router{
+		routes: make([]*route, 0),
+		debug:  false,
+	}
+}
+
+// SetDebug enables or disables additional debug information in Router generated errors. Default is disabled.
+func (r *Router) SetDebug(debug Debug) *Router {
+	r.debug = debug
+	return r
+}
+
+// AddFunction registers a Function for the given method and resource pattern. Resource patterns use the API Gateway
+// syntax, e.g. "/users/{id}" or "/files/{path+}".
+func (r *Router) AddFunction(method, resource string, function *Function) *Router {
+	errors.Assert(method != "", "method must not be empty")
+	errors.Assert(strings.HasPrefix(resource, "/"), "resource must start with '/'")
+	errors.Assert(function != nil, "function must not be nil")
+
+	r.routes = append(r.routes, &route{
+		method:   strings.ToUpper(method),
+		resource: resource,
+		segments: splitPath(resource),
+		function: function,
+	})
+
+	sort.SliceStable(r.routes, func(i, j int) bool {
+		return isMoreSpecific(r.routes[i].segments, r.routes[j].segments)
+	})
+
+	return r
+}
+
+// Handler provides a handler function suitable for lambda.Start().
+//
+// CORS preflight requests that do not match any route are dispatched to the Function(s) registered for the requested
+// method, if they have CORS enabled.
+func (r *Router) Handler(ctx context.Context, in events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	route, pathParameters, allowedMethods := r.match(in.HTTPMethod, &in)
+
+	if headers := newMultiGet(in.Headers, in.MultiValueHeaders); route == nil && isPreflightRequest(in.HTTPMethod, headers) {
+		preflightRoute, preflightPathParameters, _ := r.match(headers.Get("Access-Control-Request-Method"), &in)
+		if preflightRoute != nil && preflightRoute.function.cors != nil {
+			route, pathParameters = preflightRoute, preflightPathParameters
+		}
+	}
+
+	if route != nil {
+		if pathParameters != nil {
+			in.Resource = route.resource
+			in.PathParameters = mergePathParameters(in.PathParameters, pathParameters)
+		}
+		return route.function.Handler(ctx, in)
+	}
+
+	ctx = populateContext(ctx, r.debug, &in)
+
+	if len(allowedMethods) > 0 {
+		out := adaptError(ctx, errors.Errorf("method '%v' not allowed for path '%v'", in.HTTPMethod, in.Path, methodNotAllowed))
+		out.Headers["Allow"] = strings.Join(allowedMethods, ", ")
+		return *out, nil
+	}
+
+	return *adaptError(ctx, errors.Errorf("no route for path '%v'", in.Path, routeNotFound)), nil
+}
+
+// Start invokes lambda.Start() passing the Router handler as argument.
+func (r *Router) Start() {
+	lambda.Start(r.Handler)
+}
+
+func (r *Router) match(method string, in *events.APIGatewayProxyRequest) (*route, map[string]string, []string) {
+	if route := r.matchResource(method, in.Resource); route != nil {
+		return route, nil, nil
+	}
+	return r.matchPath(method, in.Path)
+}
+
+func (r *Router) matchResource(method, resource string) *route {
+	for _, route := range r.routes {
+		if route.resource == resource && route.matchMethod(method) {
+			return route
+		}
+	}
+	return nil
+}
+
+func (r *Router) matchPath(method, path string) (*route, map[string]string, []string) {
+	segments := splitPath(path)
+	allowedMethods := make(map[string]struct{})
+
+	for _, route := range r.routes {
+		pathParameters, ok := route.matchSegments(segments)
+		if !ok {
+			continue
+		}
+		if route.matchMethod(method) {
+			return route, pathParameters, nil
+		}
+		allowedMethods[route.method] = struct{}{}
+	}
+
+	return nil, nil, sortedKeys(allowedMethods)
+}
+
+func (r *route) matchMethod(method string) bool {
+	return r.method == AnyMethod || r.method == strings.ToUpper(method)
+}
+
+func (r *route) matchSegments(segments []string) (map[string]string, bool) {
+	pathParameters := make(map[string]string)
+
+	for i, pattern := range r.segments {
+		if strings.HasPrefix(pattern, "{") && strings.HasSuffix(pattern, "+}") {
+			if i >= len(segments) {
+				return nil, false
+			}
+			pathParameters[pattern[1:len(pattern)-2]] = strings.Join(segments[i:], "/")
+			return pathParameters, true
+		}
+
+		if i >= len(segments) {
+			return nil, false
+		}
+
+		if strings.HasPrefix(pattern, "{") && strings.HasSuffix(pattern, "}") {
+			pathParameters[pattern[1:len(pattern)-1]] = segments[i]
+			continue
+		}
+
+		if pattern != segments[i] {
+			return nil, false
+		}
+	}
+
+	return pathParameters, len(segments) == len(r.segments)
+}
+
+// isMoreSpecific returns true if pattern a should be tried before pattern b.
+func isMoreSpecific(a, b []string) bool {
+	for i := 0; i < len(a) && i < len(b); i++ {
+		if rankA, rankB := rankSegment(a[i]), rankSegment(b[i]); rankA != rankB {
+			return rankA < rankB
+		}
+	}
+	return len(a) > len(b)
+}
+
+// rankSegment ranks literal segments first, then variables, then greedy variables.
+func rankSegment(segment string) int {
+	switch {
+	case strings.HasPrefix(segment, "{") && strings.HasSuffix(segment, "+}"):
+		return 2
+	case strings.HasPrefix(segment, "{") && strings.HasSuffix(segment, "}"):
+		return 1
+	default:
+		return 0
+	}
+}
+
+func splitPath(path string) []string {
+	path = strings.Trim(path, "/")
+	if path == "" {
+		return []string{}
+	}
+	return strings.Split(path, "/")
+}
+
+func mergePathParameters(original, extracted map[string]string) map[string]string {
+	merged := make(map[string]string, len(original)+len(extracted))
+	for k, v := range original {
+		merged[k] = v
+	}
+	for k, v := range extracted {
+		merged[k] = v
+	}
+	return merged
+}
+
+func sortedKeys(m map[string]struct{}) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
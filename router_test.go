@@ -0,0 +1,184 @@
+package mbd
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/stretchr/testify/require"
+)
+
+func newRouterTestFunction(name string) *Function {
+	return NewFunction(nil, func(ctx context.Context, _ interface{}) (interface{}, error) {
+		return map[string]interface{}{
+			"name":           name,
+			"resource":       GetPath(ctx).Resource,
+			"pathParameters": GetPathParameters(ctx).Map(),
+		}, nil
+	})
+}
+
+func newRouterTestRouter() *Router {
+	return NewRouter().
+		AddFunction("GET", "/users", newRouterTestFunction("listUsers")).
+		AddFunction("POST", "/users", newRouterTestFunction("createUser")).
+		AddFunction("GET", "/users/{id}", newRouterTestFunction("getUser")).
+		AddFunction(AnyMethod, "/files/{path+}", newRouterTestFunction("files"))
+}
+
+func parseRouterTestResponse(t *testing.T, out events.APIGatewayProxyResponse) map[string]interface{} {
+	resp := map[string]interface{}{}
+	require.NoError(t, json.Unmarshal([]byte(out.Body), &resp))
+	return resp
+}
+
+func TestRouter_MatchResource(t *testing.T) {
+	out, err := newRouterTestRouter().Handler(context.Background(), events.APIGatewayProxyRequest{
+		Resource:       "/users/{id}",
+		Path:           "/users/1",
+		HTTPMethod:     "GET",
+		PathParameters: map[string]string{"id": "1"},
+	})
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, out.StatusCode)
+	require.Equal(t, map[string]interface{}{
+		"name":           "getUser",
+		"resource":       "/users/{id}",
+		"pathParameters": map[string]interface{}{"id": "1"},
+	}, parseRouterTestResponse(t, out))
+}
+
+func TestRouter_MatchPath(t *testing.T) {
+	out, err := newRouterTestRouter().Handler(context.Background(), events.APIGatewayProxyRequest{
+		Resource:       "/{proxy+}",
+		Path:           "/users/1",
+		HTTPMethod:     "GET",
+		PathParameters: map[string]string{"proxy": "users/1"},
+	})
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, out.StatusCode)
+	require.Equal(t, map[string]interface{}{
+		"name":           "getUser",
+		"resource":       "/users/{id}",
+		"pathParameters": map[string]interface{}{"id": "1", "proxy": "users/1"},
+	}, parseRouterTestResponse(t, out))
+
+	out, err = newRouterTestRouter().Handler(context.Background(), events.APIGatewayProxyRequest{
+		Resource:   "/{proxy+}",
+		Path:       "/users",
+		HTTPMethod: "POST",
+	})
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, out.StatusCode)
+	require.Equal(t, "createUser", parseRouterTestResponse(t, out)["name"])
+}
+
+func TestRouter_MatchGreedyPath(t *testing.T) {
+	out, err := newRouterTestRouter().Handler(context.Background(), events.APIGatewayProxyRequest{
+		Resource:   "/{proxy+}",
+		Path:       "/files/a/b/c.txt",
+		HTTPMethod: "DELETE",
+	})
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, out.StatusCode)
+	require.Equal(t, map[string]interface{}{
+		"name":           "files",
+		"resource":       "/files/{path+}",
+		"pathParameters": map[string]interface{}{"path": "a/b/c.txt"},
+	}, parseRouterTestResponse(t, out))
+}
+
+func TestRouter_MatchSpecificPath(t *testing.T) {
+	r := NewRouter().
+		AddFunction(AnyMethod, "/{path+}", newRouterTestFunction("catchAll")).
+		AddFunction("GET", "/users/{id}", newRouterTestFunction("getUser")).
+		AddFunction("GET", "/users/me", newRouterTestFunction("getMe")).
+		AddFunction("GET", "/users/{id}/{path+}", newRouterTestFunction("userFiles")).
+		AddFunction("GET", "/users/{id}/settings", newRouterTestFunction("userSettings"))
+
+	for path, name := range map[string]string{
+		"/users/me":            "getMe",
+		"/users/1":             "getUser",
+		"/users/1/settings":    "userSettings",
+		"/users/1/files/a.txt": "userFiles",
+		"/other":               "catchAll",
+	} {
+		out, err := r.Handler(context.Background(), events.APIGatewayProxyRequest{
+			Resource:   "/{proxy+}",
+			Path:       path,
+			HTTPMethod: "GET",
+		})
+		require.NoError(t, err)
+		require.Equal(t, http.StatusOK, out.StatusCode)
+		require.Equal(t, name, parseRouterTestResponse(t, out)["name"], path)
+	}
+}
+
+func TestRouter_NotFound(t *testing.T) {
+	for _, path := range []string{"/", "/other", "/users/1/other", "/files"} {
+		out, err := newRouterTestRouter().SetDebug(true).Handler(context.Background(), events.APIGatewayProxyRequest{
+			Resource:       "/{proxy+}",
+			Path:           path,
+			HTTPMethod:     "GET",
+			RequestContext: events.APIGatewayProxyRequestContext{RequestID: "test"},
+		})
+		require.NoError(t, err)
+		require.Equal(t, http.StatusNotFound, out.StatusCode)
+
+		errResp := &ErrorResponse{}
+		require.NoError(t, json.Unmarshal([]byte(out.Body), errResp))
+		require.Equal(t, http.StatusNotFound, errResp.StatusCode)
+		require.Equal(t, "not-found", errResp.PublicMessage)
+		require.Equal(t, "test", errResp.RequestID)
+		require.Len(t, errResp.Errors, 1)
+		require.Equal(t, "no route for path '"+path+"'", errResp.Errors[0].Error)
+	}
+}
+
+func TestRouter_MethodNotAllowed(t *testing.T) {
+	out, err := newRouterTestRouter().Handler(context.Background(), events.APIGatewayProxyRequest{
+		Resource:   "/{proxy+}",
+		Path:       "/users",
+		HTTPMethod: "DELETE",
+	})
+	require.NoError(t, err)
+	require.Equal(t, http.StatusMethodNotAllowed, out.StatusCode)
+	require.Equal(t, "GET, POST", out.Headers["Allow"])
+
+	errResp := &ErrorResponse{}
+	require.NoError(t, json.Unmarshal([]byte(out.Body), errResp))
+	require.Equal(t, http.StatusMethodNotAllowed, errResp.StatusCode)
+	require.Equal(t, "method-not-allowed", errResp.PublicMessage)
+	require.Empty(t, errResp.Errors)
+}
+
+func TestRouter_Preflight(t *testing.T) {
+	r := newRouterTestRouter().
+		AddFunction("PUT", "/users/{id}", newRouterTestFunction("updateUser").SetCORS(&CORSConfig{AllowedOrigins: []string{"*"}}))
+
+	out, err := r.Handler(context.Background(), events.APIGatewayProxyRequest{
+		Path:       "/users/1",
+		HTTPMethod: "OPTIONS",
+		Headers: map[string]string{
+			"Origin":                        "https://example.com",
+			"Access-Control-Request-Method": "PUT",
+		},
+	})
+	require.NoError(t, err)
+	require.Equal(t, http.StatusNoContent, out.StatusCode)
+	require.Equal(t, "*", out.Headers["Access-Control-Allow-Origin"])
+
+	out, err = r.Handler(context.Background(), events.APIGatewayProxyRequest{
+		Path:       "/users/1",
+		HTTPMethod: "OPTIONS",
+		Headers: map[string]string{
+			"Origin":                        "https://example.com",
+			"Access-Control-Request-Method": "GET",
+		},
+	})
+	require.NoError(t, err)
+	require.Equal(t, http.StatusMethodNotAllowed, out.StatusCode)
+	require.Equal(t, "GET, PUT", out.Headers["Allow"])
+}
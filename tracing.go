@@ -0,0 +1,253 @@
+package mbd
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ibrt/errors"
+)
+
+// Span describes a timed operation within a trace. Trace and span IDs are lowercase hex strings in W3C trace context
+// format, X-Ray trace IDs are converted to and from it.
+type Span struct {
+	Name         string
+	TraceID      string
+	SpanID       string
+	ParentSpanID string
+	Sampled      bool
+	StartTime    time.Time
+	EndTime      time.Time
+	Attributes   map[string]interface{}
+	Error        string
+
+	m        *sync.Mutex
+	exporter SpanExporter
+}
+
+// SpanExporter receives ended spans, e.g. to forward them to a tracing backend.
+type SpanExporter interface {
+	ExportSpan(span *Span)
+}
+
+// SpanExporterFunc allows using a function as SpanExporter.
+type SpanExporterFunc func(span *Span)
+
+// ExportSpan implements SpanExporter.
+func (f SpanExporterFunc) ExportSpan(span *Span) {
+	f(span)
+}
+
+// MemorySpanExporter is a SpanExporter that stores ended spans in memory, useful for testing.
+type MemorySpanExporter struct {
+	m     *sync.Mutex
+	spans []*Span
+}
+
+// NewMemorySpanExporter initializes a new MemorySpanExporter.
+func NewMemorySpanExporter() *MemorySpanExporter {
+	return &MemorySpanExporter{
+		m:     &sync.Mutex{},
+		spans: make([]*Span, 0),
+	}
+}
+
+// ExportSpan implements SpanExporter.
+func (e *MemorySpanExporter) ExportSpan(span *Span) {
+	e.m.Lock()
+	defer e.m.Unlock()
+	e.spans = append(e.spans, span)
+}
+
+// GetSpans returns the stored spans, in the order they ended.
+func (e *MemorySpanExporter) GetSpans() []*Span {
+	e.m.Lock()
+	defer e.m.Unlock()
+	return append([]*Span{}, e.spans...)
+}
+
+// Reset discards the stored spans.
+func (e *MemorySpanExporter) Reset() {
+	e.m.Lock()
+	defer e.m.Unlock()
+	e.spans = make([]*Span, 0)
+}
+
+// GetSpan returns the active Span stored in context. If tracing is disabled, it returns nil.
+func GetSpan(ctx context.Context) *Span {
+	if span, ok := ctx.Value(spanContextKey).(*Span); ok {
+		return span
+	}
+	return nil
+}
+
+// StartSpan starts a child of the active Span stored in context, and returns a context in which it is the active Span.
+// If tracing is disabled, the returned Span is never exported. The Span must be ended by calling End().
+func StartSpan(ctx context.Context, name string) (context.Context, *Span) {
+	span := startChildSpan(ctx, name)
+	return context.WithValue(ctx, spanContextKey, span), span
+}
+
+// SetAttribute sets an attribute on the Span.
+func (s *Span) SetAttribute(key string, value interface{}) *Span {
+	s.m.Lock()
+	defer s.m.Unlock()
+	s.Attributes[key] = value
+	return s
+}
+
+// SetError records the given error on the Span, if not nil.
+func (s *Span) SetError(err error) *Span {
+	if err != nil {
+		s.m.Lock()
+		defer s.m.Unlock()
+		s.Error = err.Error()
+	}
+	return s
+}
+
+// End ends the Span and exports it, if sampled. Subsequent calls have no effect.
+func (s *Span) End() {
+	s.m.Lock()
+	if !s.EndTime.IsZero() {
+		s.m.Unlock()
+		return
+	}
+	s.EndTime = time.Now()
+	s.m.Unlock()
+
+	if s.exporter != nil && s.Sampled {
+		s.exporter.ExportSpan(s)
+	}
+}
+
+// TraceParent returns a W3C "traceparent" header value that propagates the Span.
+func (s *Span) TraceParent() string {
+	flags := "00"
+	if s.Sampled {
+		flags = "01"
+	}
+	return fmt.Sprintf("00-%v-%v-%v", s.TraceID, s.SpanID, flags)
+}
+
+// XRayTraceHeader returns an "X-Amzn-Trace-Id" header value that propagates the Span.
+func (s *Span) XRayTraceHeader() string {
+	sampled := "0"
+	if s.Sampled {
+		sampled = "1"
+	}
+	return fmt.Sprintf("Root=1-%v-%v;Parent=%v;Sampled=%v", s.TraceID[:8], s.TraceID[8:], s.SpanID, sampled)
+}
+
+// InjectHeaders sets the "traceparent" and "X-Amzn-Trace-Id" headers that propagate the Span, e.g. on outgoing requests.
+func (s *Span) InjectHeaders(h http.Header) {
+	h.Set("traceparent", s.TraceParent())
+	h.Set("X-Amzn-Trace-Id", s.XRayTraceHeader())
+}
+
+// startRootSpan starts a Span that continues the trace propagated by the request headers, if any.
+func startRootSpan(ctx context.Context, name string, exporter SpanExporter) *Span {
+	traceID, parentSpanID, sampled, ok := parseTraceParent(GetHeaders(ctx).Get("traceparent"))
+	if !ok {
+		traceID, parentSpanID, sampled, ok = parseXRayTraceHeader(GetHeaders(ctx).Get("X-Amzn-Trace-Id"))
+	}
+	if !ok {
+		traceID, parentSpanID, sampled = newTraceID(), "", true
+	}
+
+	return newSpan(name, traceID, parentSpanID, sampled, exporter)
+}
+
+// startChildSpan starts a child of the active Span stored in context, without making it active.
+func startChildSpan(ctx context.Context, name string) *Span {
+	if parent := GetSpan(ctx); parent != nil {
+		return newSpan(name, parent.TraceID, parent.SpanID, parent.Sampled, parent.exporter)
+	}
+	return newSpan(name, newTraceID(), "", false, nil)
+}
+
+func newSpan(name, traceID, parentSpanID string, sampled bool, exporter SpanExporter) *Span {
+	return &Span{
+		Name:         name,
+		TraceID:      traceID,
+		SpanID:       newRandomHex(8),
+		ParentSpanID: parentSpanID,
+		Sampled:      sampled,
+		StartTime:    time.Now(),
+		Attributes:   make(map[string]interface{}),
+		m:            &sync.Mutex{},
+		exporter:     exporter,
+	}
+}
+
+// newTraceID generates a trace ID whose first 4 bytes are the current epoch, so that it is also valid for X-Ray.
+func newTraceID() string {
+	return fmt.Sprintf("%08x", time.Now().Unix()) + newRandomHex(12)
+}
+
+func newRandomHex(n int) string {
+	buf := make([]byte, n)
+	_, err := rand.Read(buf)
+	errors.MaybeMustWrap(err)
+	return hex.EncodeToString(buf)
+}
+
+// parseTraceParent parses a W3C "traceparent" header value.
+func parseTraceParent(traceParent string) (traceID, parentSpanID string, sampled, ok bool) {
+	parts := strings.Split(strings.TrimSpace(traceParent), "-")
+	if len(parts) < 4 || parts[0] == "ff" || !isTraceHex(parts[0], 2) || !isTraceHex(parts[1], 32) || !isTraceHex(parts[2], 16) || !isTraceHex(parts[3], 2) {
+		return "", "", false, false
+	}
+
+	flags, _ := hex.DecodeString(parts[3])
+	return parts[1], parts[2], flags[0]&1 == 1, true
+}
+
+// parseXRayTraceHeader parses an "X-Amzn-Trace-Id" header value.
+func parseXRayTraceHeader(traceHeader string) (traceID, parentSpanID string, sampled, ok bool) {
+	sampled = true
+
+	for _, part := range strings.Split(traceHeader, ";") {
+		kv := strings.SplitN(strings.TrimSpace(part), "=", 2)
+		if len(kv) != 2 {
+			continue
+		}
+
+		switch kv[0] {
+		case "Root":
+			if root := strings.Split(kv[1], "-"); len(root) == 3 && root[0] == "1" && isTraceHex(root[1], 8) && isTraceHex(root[2], 24) {
+				traceID = root[1] + root[2]
+			}
+		case "Parent":
+			if isTraceHex(kv[1], 16) {
+				parentSpanID = kv[1]
+			}
+		case "Sampled":
+			sampled = kv[1] != "0"
+		}
+	}
+
+	if traceID == "" {
+		return "", "", false, false
+	}
+
+	return traceID, parentSpanID, sampled, true
+}
+
+// isTraceHex returns true if s is a lowercase hex string of length n, not all zeros for IDs.
+func isTraceHex(s string, n int) bool {
+	if len(s) != n || (n > 2 && strings.Trim(s, "0") == "") {
+		return false
+	}
+	for _, c := range s {
+		if !(c >= '0' && c <= '9' || c >= 'a' && c <= 'f') {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,137 @@
+package mbd
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/ibrt/errors"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseTraceParent(t *testing.T) {
+	traceID, parentSpanID, sampled, ok := parseTraceParent("00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	require.True(t, ok)
+	require.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", traceID)
+	require.Equal(t, "00f067aa0ba902b7", parentSpanID)
+	require.True(t, sampled)
+
+	_, _, sampled, ok = parseTraceParent("00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-00")
+	require.True(t, ok)
+	require.False(t, sampled)
+
+	for _, traceParent := range []string{
+		"",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7",
+		"ff-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+		"00-00000000000000000000000000000000-00f067aa0ba902b7-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-0000000000000000-01",
+		"00-4BF92F3577B34DA6A3CE929D0E0E4736-00f067aa0ba902b7-01",
+	} {
+		_, _, _, ok = parseTraceParent(traceParent)
+		require.False(t, ok, traceParent)
+	}
+}
+
+func TestParseXRayTraceHeader(t *testing.T) {
+	traceID, parentSpanID, sampled, ok := parseXRayTraceHeader("Root=1-5759e988-bd862e3fe1be46a994272793;Parent=53995c3f42cd8ad8;Sampled=1")
+	require.True(t, ok)
+	require.Equal(t, "5759e988bd862e3fe1be46a994272793", traceID)
+	require.Equal(t, "53995c3f42cd8ad8", parentSpanID)
+	require.True(t, sampled)
+
+	traceID, parentSpanID, sampled, ok = parseXRayTraceHeader("Root=1-5759e988-bd862e3fe1be46a994272793;Sampled=0")
+	require.True(t, ok)
+	require.Equal(t, "5759e988bd862e3fe1be46a994272793", traceID)
+	require.Equal(t, "", parentSpanID)
+	require.False(t, sampled)
+
+	for _, traceHeader := range []string{"", "Root=2-5759e988-bd862e3fe1be46a994272793", "Parent=53995c3f42cd8ad8;Sampled=1", "Root"} {
+		_, _, _, ok = parseXRayTraceHeader(traceHeader)
+		require.False(t, ok, traceHeader)
+	}
+}
+
+func TestSpan_Headers(t *testing.T) {
+	span := newSpan("test", "5759e988bd862e3fe1be46a994272793", "", true, nil)
+	span.SpanID = "53995c3f42cd8ad8"
+	require.Equal(t, "00-5759e988bd862e3fe1be46a994272793-53995c3f42cd8ad8-01", span.TraceParent())
+	require.Equal(t, "Root=1-5759e988-bd862e3fe1be46a994272793;Parent=53995c3f42cd8ad8;Sampled=1", span.XRayTraceHeader())
+
+	span.Sampled = false
+	h := http.Header{}
+	span.InjectHeaders(h)
+	require.Equal(t, "00-5759e988bd862e3fe1be46a994272793-53995c3f42cd8ad8-00", h.Get("traceparent"))
+	require.Equal(t, "Root=1-5759e988-bd862e3fe1be46a994272793;Parent=53995c3f42cd8ad8;Sampled=0", h.Get("X-Amzn-Trace-Id"))
+}
+
+func TestStartSpan(t *testing.T) {
+	require.Nil(t, GetSpan(context.Background()))
+
+	ctx, span := StartSpan(context.Background(), "test")
+	require.Equal(t, span, GetSpan(ctx))
+	require.Len(t, span.TraceID, 32)
+	require.Len(t, span.SpanID, 16)
+	require.False(t, span.Sampled)
+	span.End()
+	require.NotZero(t, span.EndTime)
+
+	exporter := NewMemorySpanExporter()
+	parent := newSpan("parent", newTraceID(), "", true, exporter)
+	ctx, span = StartSpan(context.WithValue(context.Background(), spanContextKey, parent), "child")
+	require.Equal(t, span, GetSpan(ctx))
+	require.Equal(t, parent.TraceID, span.TraceID)
+	require.Equal(t, parent.SpanID, span.ParentSpanID)
+	span.SetAttribute("k", "v").SetError(errors.Errorf("test error")).End()
+	span.End()
+	require.Equal(t, []*Span{span}, exporter.GetSpans())
+	require.Equal(t, map[string]interface{}{"k": "v"}, span.Attributes)
+	require.Equal(t, "test error", span.Error)
+
+	exporter.Reset()
+	require.Empty(t, exporter.GetSpans())
+}
+
+func TestFunction_Tracing(t *testing.T) {
+	exporter := NewMemorySpanExporter()
+
+	f := NewFunction(nil, func(ctx context.Context, req interface{}) (interface{}, error) {
+		_, span := StartSpan(ctx, "outgoing")
+		span.End()
+		return nil, errors.Errorf("test error", errors.HTTPStatusConflict)
+	}).SetTracing(exporter)
+
+	_, err := f.Handler(context.Background(), events.APIGatewayProxyRequest{
+		Resource:   "/resource",
+		HTTPMethod: http.MethodGet,
+		Headers:    map[string]string{"traceparent": "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"},
+	})
+	require.NoError(t, err)
+
+	spans := exporter.GetSpans()
+	require.Len(t, spans, 6)
+
+	names := make([]string, len(spans))
+	for i, span := range spans {
+		names[i] = span.Name
+		require.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", span.TraceID)
+	}
+	require.Equal(t, []string{"providers", "parse", "checkers", "outgoing", "handler", "GET /resource"}, names)
+
+	root := spans[5]
+	require.Equal(t, "00f067aa0ba902b7", root.ParentSpanID)
+	require.Equal(t, http.StatusConflict, root.Attributes["http.status_code"])
+	require.Equal(t, "test error", root.Error)
+	require.Equal(t, root.SpanID, spans[0].ParentSpanID)
+	require.Equal(t, root.SpanID, spans[4].ParentSpanID)
+	require.Equal(t, spans[4].SpanID, spans[3].ParentSpanID)
+	require.Equal(t, "test error", spans[4].Error)
+
+	exporter.Reset()
+	_, err = f.Handler(context.Background(), events.APIGatewayProxyRequest{
+		Headers: map[string]string{"X-Amzn-Trace-Id": "Root=1-5759e988-bd862e3fe1be46a994272793;Sampled=0"},
+	})
+	require.NoError(t, err)
+	require.Empty(t, exporter.GetSpans())
+}
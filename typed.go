@@ -0,0 +1,56 @@
+package mbd
+
+import (
+	"context"
+	"reflect"
+
+	"github.com/ibrt/errors"
+)
+
+// Empty can be used as Req or Resp type parameter of NewTypedFunction to denote a request or response without body.
+type Empty struct {
+	// intentionally empty
+}
+
+// TypedHandler implements a type-safe Lambda function handler.
+// If Req is Empty, req is always nil. If Resp is Empty, resp is ignored.
+type TypedHandler[Req, Resp any] func(ctx context.Context, req *Req) (resp *Resp, err error)
+
+var (
+	emptyType = reflect.TypeOf(Empty{})
+)
+
+// NewTypedFunction initializes a new Function with a type-safe handler. The request type is derived from Req, which must
+// be a struct type (or Empty). Custom RequestParser(s) must return values of type *Req.
+func NewTypedFunction[Req, Resp any](handler TypedHandler[Req, Resp]) *Function {
+	errors.Assert(handler != nil, "handler must not be nil")
+
+	var reqTemplate interface{} = *new(Req)
+	if reflect.TypeOf(reqTemplate) == emptyType {
+		reqTemplate = nil
+	}
+
+	_, emptyResp := interface{}(*new(Resp)).(Empty)
+
+	return NewFunction(reqTemplate, func(ctx context.Context, req interface{}) (interface{}, error) { // Handler
+		var typedReq *Req
+
+		if req != nil {
+			var ok bool
+			if typedReq, ok = req.(*Req); !ok {
+				return nil, errors.Errorf("invalid request type: expected '%T', got '%T'", typedReq, req)
+			}
+		}
+
+		resp, err := handler(ctx, typedReq)
+		if err != nil {
+			return nil, err
+		}
+
+		if resp == nil || emptyResp {
+			return nil, nil
+		}
+
+		return resp, nil
+	})
+}
@@ -0,0 +1,68 @@
+package mbd
+
+import (
+	"context"
+	"net/http"
+	"reflect"
+	"testing"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/stretchr/testify/require"
+)
+
+type typedTestRequest struct {
+	Value string `json:"value"`
+}
+
+type typedTestResponse struct {
+	Value string `json:"value"`
+}
+
+func TestNewTypedFunction(t *testing.T) {
+	f := NewTypedFunction(func(ctx context.Context, req *typedTestRequest) (*typedTestResponse, error) {
+		return &typedTestResponse{Value: req.Value}, nil
+	})
+	require.Equal(t, reflect.TypeOf(typedTestRequest{}), f.reqType)
+
+	out, err := f.Handler(context.Background(), events.APIGatewayProxyRequest{Body: `{"value":"v"}`})
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, out.StatusCode)
+	require.JSONEq(t, `{"value":"v"}`, out.Body)
+}
+
+func TestNewTypedFunction_Empty(t *testing.T) {
+	f := NewTypedFunction(func(ctx context.Context, req *Empty) (*Empty, error) {
+		require.Nil(t, req)
+		return &Empty{}, nil
+	})
+	require.Equal(t, noRequestBody, f.reqType)
+
+	out, err := f.Handler(context.Background(), events.APIGatewayProxyRequest{})
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, out.StatusCode)
+	require.Empty(t, out.Body)
+}
+
+func TestNewTypedFunction_NilResponse(t *testing.T) {
+	f := NewTypedFunction(func(ctx context.Context, req *typedTestRequest) (*typedTestResponse, error) {
+		return nil, nil
+	})
+
+	out, err := f.Handler(context.Background(), events.APIGatewayProxyRequest{Body: `{"value":"v"}`})
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, out.StatusCode)
+	require.Empty(t, out.Body)
+}
+
+func TestNewTypedFunction_InvalidRequestType(t *testing.T) {
+	f := NewTypedFunction(func(ctx context.Context, req *typedTestRequest) (*typedTestResponse, error) {
+		return nil, nil
+	}).SetDebug(true).SetRequestParser(func(context.Context, reflect.Type, *events.APIGatewayProxyRequest) (interface{}, error) {
+		return &typedTestResponse{}, nil
+	})
+
+	out, err := f.Handler(context.Background(), events.APIGatewayProxyRequest{})
+	require.NoError(t, err)
+	require.Equal(t, http.StatusInternalServerError, out.StatusCode)
+	require.Contains(t, out.Body, "invalid request type: expected '*mbd.typedTestRequest', got '*mbd.typedTestResponse'")
+}
@@ -0,0 +1,383 @@
+package mbd
+
+import (
+	"fmt"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode/utf8"
+
+	"github.com/ibrt/errors"
+)
+
+const (
+	validationTag = "validation"
+)
+
+// ValidationError describes a validation rule that failed on a request field.
+// Field is a path built from JSON field names, e.g. "items[0].name".
+type ValidationError struct {
+	Field string `json:"field"`
+	Rule  string `json:"rule"`
+	Param string `json:"param,omitempty"`
+}
+
+type validationErrorsMetadataKey int
+
+type validationField struct {
+	index     int
+	name      string
+	anonymous bool
+	rules     []*validationRule
+}
+
+type validationRule struct {
+	rule  string
+	name  string
+	param string
+	n     float64
+	enum  []string
+	re    *regexp.Regexp
+}
+
+var (
+	validationFieldsCache = &sync.Map{}
+)
+
+// GetValidationErrors returns the ValidationError(s) stored in the error metadata, if any.
+func GetValidationErrors(err error) []*ValidationError {
+	if validationErrors, ok := errors.GetMetadata(err, validationErrorsMetadataKey(0)).([]*ValidationError); ok {
+		return validationErrors
+	}
+	return nil
+}
+
+// validateRequest validates the given request against the rules in its "validation" struct tags, recursing into nested
+// structs, pointers and slices. The tag is a comma-separated list of rules:
+//
+//   - required: the value must not be the zero value (or nil, or empty)
+//   - min=N, max=N: minimum/maximum value for numbers, minimum/maximum length for strings, slices and maps
+//   - len=N: exact length for strings, slices and maps
+//   - enum=a|b|c: the value must be one of the given values
+//   - regexp=EXPR: strings must match the given regular expression, must be the last rule as it can contain commas
+//
+// Nil pointers are only checked against the "required" rule. Rules are parsed once per type, see checkValidationRules.
+func validateRequest(req interface{}) error {
+	if req == nil {
+		return nil
+	}
+
+	validationErrors := make([]*ValidationError, 0)
+	if err := validateValue(reflect.ValueOf(req), "", &validationErrors); err != nil {
+		return err
+	}
+
+	if len(validationErrors) > 0 {
+		return errors.Errorf("validation failed: %v", formatValidationErrors(validationErrors),
+			validationFailed,
+			errors.Metadata(validationErrorsMetadataKey(0), validationErrors))
+	}
+
+	return nil
+}
+
+// checkValidationRules parses the validation rules of the given type and of the types it contains, returning an error if
+// any of them is invalid. Types only reachable through interfaces are parsed on first use instead.
+func checkValidationRules(t reflect.Type) error {
+	return checkValidationType(t, "", map[reflect.Type]bool{})
+}
+
+func checkValidationType(t reflect.Type, path string, visited map[reflect.Type]bool) error {
+	for t.Kind() == reflect.Ptr || t.Kind() == reflect.Slice || t.Kind() == reflect.Array {
+		if t.Kind() != reflect.Ptr {
+			path += "[]"
+		}
+		t = t.Elem()
+	}
+
+	if t.Kind() != reflect.Struct || visited[t] {
+		return nil
+	}
+	visited[t] = true
+
+	fields, err := getValidationFields(t)
+	if err != nil {
+		if path != "" {
+			return errors.Wrap(err, errors.Prefix("%v", path))
+		}
+		return err
+	}
+
+	for _, field := range fields {
+		fieldPath := path
+		if !field.anonymous {
+			fieldPath = joinValidationPath(path, field.name)
+		}
+
+		if err := checkValidationType(t.Field(field.index).Type, fieldPath, visited); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func validateValue(v reflect.Value, path string, validationErrors *[]*ValidationError) error {
+	switch v.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		if !v.IsNil() {
+			return validateValue(v.Elem(), path, validationErrors)
+		}
+	case reflect.Slice, reflect.Array:
+		switch v.Type().Elem().Kind() {
+		case reflect.Ptr, reflect.Interface, reflect.Slice, reflect.Array, reflect.Struct:
+		default:
+			return nil
+		}
+
+		for i := 0; i < v.Len(); i++ {
+			if err := validateValue(v.Index(i), fmt.Sprintf("%v[%v]", path, i), validationErrors); err != nil {
+				return err
+			}
+		}
+	case reflect.Struct:
+		fields, err := getValidationFields(v.Type())
+		if err != nil {
+			return err
+		}
+
+		for _, field := range fields {
+			fieldPath := path
+			if !field.anonymous {
+				fieldPath = joinValidationPath(path, field.name)
+			}
+
+			fieldValue := v.Field(field.index)
+
+			for _, rule := range field.rules {
+				ok, err := rule.check(fieldValue)
+				if err != nil {
+					return errors.Wrap(err, errors.Prefix("invalid validation rule '%v' on field '%v'", rule.rule, fieldPath))
+				}
+
+				if !ok {
+					*validationErrors = append(*validationErrors, &ValidationError{
+						Field: fieldPath,
+						Rule:  rule.name,
+						Param: rule.param,
+					})
+				}
+			}
+
+			if err := validateValue(fieldValue, fieldPath, validationErrors); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// getValidationFields returns the fields of the given struct type that validation recurses into, with their parsed rules.
+func getValidationFields(t reflect.Type) ([]*validationField, error) {
+	if fields, ok := validationFieldsCache.Load(t); ok {
+		return fields.([]*validationField), nil
+	}
+
+	fields := make([]*validationField, 0, t.NumField())
+
+	for i := 0; i < t.NumField(); i++ {
+		structField := t.Field(i)
+		if structField.PkgPath != "" && !(structField.Anonymous && structField.Type.Kind() == reflect.Struct) {
+			continue
+		}
+
+		field := &validationField{
+			index:     i,
+			name:      getValidationFieldName(structField),
+			anonymous: structField.Anonymous,
+		}
+
+		if tag, ok := structField.Tag.Lookup(validationTag); ok {
+			rules, err := parseValidationRules(tag, structField.Type, field.name)
+			if err != nil {
+				return nil, err
+			}
+			field.rules = rules
+		}
+
+		fields = append(fields, field)
+	}
+
+	validationFieldsCache.Store(t, fields)
+	return fields, nil
+}
+
+func parseValidationRules(tag string, t reflect.Type, fieldName string) ([]*validationRule, error) {
+	rules := make([]*validationRule, 0)
+
+	for tag != "" {
+		rule := &validationRule{}
+		if strings.HasPrefix(tag, "regexp=") {
+			rule.rule, tag = tag, ""
+		} else if i := strings.Index(tag, ","); i >= 0 {
+			rule.rule, tag = tag[:i], tag[i+1:]
+		} else {
+			rule.rule, tag = tag, ""
+		}
+
+		rule.name = rule.rule
+		if i := strings.Index(rule.rule, "="); i >= 0 {
+			rule.name, rule.param = rule.rule[:i], rule.rule[i+1:]
+		}
+
+		if err := rule.parse(t); err != nil {
+			return nil, errors.Wrap(err, errors.Prefix("invalid validation rule '%v' on field '%v'", rule.rule, fieldName))
+		}
+
+		rules = append(rules, rule)
+	}
+
+	return rules, nil
+}
+
+// parse parses the rule param, and checks that the rule supports the given field type.
+func (r *validationRule) parse(t reflect.Type) error {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	switch r.name {
+	case "required":
+		return nil
+	case "min", "max", "len":
+		n, err := strconv.ParseFloat(r.param, 64)
+		if err != nil {
+			return errors.Wrap(err)
+		}
+		r.n = n
+
+		if t.Kind() != reflect.Interface {
+			_, err = getValidationMeasure(reflect.Zero(t), r.name == "len")
+		}
+		return err
+	case "enum":
+		r.enum = strings.Split(r.param, "|")
+		return nil
+	case "regexp":
+		if t.Kind() != reflect.String && t.Kind() != reflect.Interface {
+			return errors.Errorf("unsupported type '%v'", t)
+		}
+
+		re, err := regexp.Compile(r.param)
+		if err != nil {
+			return errors.Wrap(err)
+		}
+		r.re = re
+		return nil
+	default:
+		return errors.Errorf("unknown rule")
+	}
+}
+
+// check checks the rule against the given value. It only returns errors for values held by interfaces, whose type is
+// not known when parsing the rule.
+func (r *validationRule) check(v reflect.Value) (bool, error) {
+	if r.name == "required" {
+		return !isEmptyValue(v), nil
+	}
+
+	for v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return true, nil
+		}
+		v = v.Elem()
+	}
+
+	switch r.name {
+	case "min", "max", "len":
+		value, err := getValidationMeasure(v, r.name == "len")
+		if err != nil {
+			return false, err
+		}
+
+		switch r.name {
+		case "min":
+			return value >= r.n, nil
+		case "max":
+			return value <= r.n, nil
+		default:
+			return value == r.n, nil
+		}
+	case "enum":
+		value := fmt.Sprint(v)
+		for _, allowed := range r.enum {
+			if value == allowed {
+				return true, nil
+			}
+		}
+		return false, nil
+	default: // regexp
+		if v.Kind() != reflect.String {
+			return false, errors.Errorf("unsupported type '%v'", v.Type())
+		}
+		return r.re.MatchString(v.String()), nil
+	}
+}
+
+func isEmptyValue(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		return v.IsNil()
+	case reflect.Slice, reflect.Map, reflect.String, reflect.Array:
+		return v.Len() == 0
+	default:
+		return v.IsZero()
+	}
+}
+
+func getValidationMeasure(v reflect.Value, lengthOnly bool) (float64, error) {
+	switch v.Kind() {
+	case reflect.String:
+		return float64(utf8.RuneCountInString(v.String())), nil
+	case reflect.Slice, reflect.Map, reflect.Array:
+		return float64(v.Len()), nil
+	}
+
+	if !lengthOnly {
+		switch v.Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			return float64(v.Int()), nil
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			return float64(v.Uint()), nil
+		case reflect.Float32, reflect.Float64:
+			return v.Float(), nil
+		}
+	}
+
+	return 0, errors.Errorf("unsupported type '%v'", v.Type())
+}
+
+func getValidationFieldName(field reflect.StructField) string {
+	if name := strings.Split(field.Tag.Get("json"), ",")[0]; name != "" && name != "-" {
+		return name
+	}
+	return field.Name
+}
+
+func joinValidationPath(path, name string) string {
+	if path == "" {
+		return name
+	}
+	return path + "." + name
+}
+
+func formatValidationErrors(validationErrors []*ValidationError) string {
+	formatted := make([]string, len(validationErrors))
+	for i, validationError := range validationErrors {
+		formatted[i] = validationError.Field + " (" + validationError.Rule + ")"
+	}
+	return strings.Join(formatted, ", ")
+}
@@ -0,0 +1,169 @@
+package mbd
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"testing"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/ibrt/errors"
+	"github.com/stretchr/testify/require"
+)
+
+type validationTestEmbedded struct {
+	Kind string `json:"kind" validation:"enum=a|b"`
+}
+
+type validationTestItem struct {
+	Name string `json:"name" validation:"required,regexp=^[a-z]{1,3}$"`
+}
+
+type validationTestRequest struct {
+	validationTestEmbedded
+	Name     string                `json:"name" validation:"required,min=2,max=5"`
+	Code     string                `json:"code" validation:"len=3"`
+	Count    int                   `json:"count" validation:"min=1,max=10"`
+	Ratio    *float64              `json:"ratio" validation:"min=0.5"`
+	Level    uint                  `json:"level" validation:"enum=1|2|3"`
+	Tags     []string              `json:"tags" validation:"max=2"`
+	Item     *validationTestItem   `json:"item" validation:"required"`
+	Items    []*validationTestItem `json:"items" validation:"min=1"`
+	Untagged validationTestItem
+}
+
+func newValidationTestRequest() *validationTestRequest {
+	ratio := 1.0
+
+	return &validationTestRequest{
+		validationTestEmbedded: validationTestEmbedded{Kind: "a"},
+		Name:                   "name",
+		Code:                   "abc",
+		Count:                  5,
+		Ratio:                  &ratio,
+		Level:                  2,
+		Tags:                   []string{"a", "b"},
+		Item:                   &validationTestItem{Name: "a"},
+		Items:                  []*validationTestItem{{Name: "b"}},
+		Untagged:               validationTestItem{Name: "c"},
+	}
+}
+
+func TestValidateRequest(t *testing.T) {
+	require.NoError(t, validateRequest(nil))
+	require.NoError(t, validateRequest(&struct{}{}))
+	require.NoError(t, validateRequest(newValidationTestRequest()))
+
+	req := newValidationTestRequest()
+	req.Ratio = nil
+	require.NoError(t, validateRequest(req))
+}
+
+func TestValidateRequest_Failed(t *testing.T) {
+	ratio := 0.1
+
+	req := &validationTestRequest{
+		validationTestEmbedded: validationTestEmbedded{Kind: "c"},
+		Name:                   "n",
+		Code:                   "abcd",
+		Count:                  11,
+		Ratio:                  &ratio,
+		Level:                  4,
+		Tags:                   []string{"a", "b", "c"},
+		Items:                  []*validationTestItem{{Name: "abcd"}, nil, {}},
+		Untagged:               validationTestItem{Name: "A"},
+	}
+
+	err := validateRequest(req)
+	require.EqualError(t, err, "validation failed: "+
+		"kind (enum), name (min), code (len), count (max), ratio (min), level (enum), tags (max), item (required), "+
+		"items[0].name (regexp), items[2].name (required), items[2].name (regexp), Untagged.name (regexp)")
+	require.Equal(t, http.StatusUnprocessableEntity, errors.GetHTTPStatus(err))
+	require.Equal(t, "validation-failed", errors.GetPublicMessage(err))
+	require.Equal(t, []*ValidationError{
+		{Field: "kind", Rule: "enum", Param: "a|b"},
+		{Field: "name", Rule: "min", Param: "2"},
+		{Field: "code", Rule: "len", Param: "3"},
+		{Field: "count", Rule: "max", Param: "10"},
+		{Field: "ratio", Rule: "min", Param: "0.5"},
+		{Field: "level", Rule: "enum", Param: "1|2|3"},
+		{Field: "tags", Rule: "max", Param: "2"},
+		{Field: "item", Rule: "required"},
+		{Field: "items[0].name", Rule: "regexp", Param: "^[a-z]{1,3}$"},
+		{Field: "items[2].name", Rule: "required"},
+		{Field: "items[2].name", Rule: "regexp", Param: "^[a-z]{1,3}$"},
+		{Field: "Untagged.name", Rule: "regexp", Param: "^[a-z]{1,3}$"},
+	}, GetValidationErrors(err))
+}
+
+func TestValidateRequest_InvalidRules(t *testing.T) {
+	require.EqualError(t, validateRequest(&struct {
+		Value string `validation:"unknown"`
+	}{}), "invalid validation rule 'unknown' on field 'Value': unknown rule")
+
+	require.EqualError(t, validateRequest(&struct {
+		Value string `validation:"min=x"`
+	}{}), `invalid validation rule 'min=x' on field 'Value': strconv.ParseFloat: parsing "x": invalid syntax`)
+
+	require.EqualError(t, validateRequest(&struct {
+		Value int `validation:"len=1"`
+	}{}), "invalid validation rule 'len=1' on field 'Value': unsupported type 'int'")
+
+	require.EqualError(t, validateRequest(&struct {
+		Value int `validation:"regexp=a"`
+	}{}), "invalid validation rule 'regexp=a' on field 'Value': unsupported type 'int'")
+
+	require.EqualError(t, validateRequest(&struct {
+		Value string `validation:"regexp=("`
+	}{}), "invalid validation rule 'regexp=(' on field 'Value': error parsing regexp: missing closing ): `(`")
+}
+
+func TestCheckValidationRules(t *testing.T) {
+	require.NoError(t, checkValidationRules(reflect.TypeOf(validationTestRequest{})))
+
+	require.EqualError(t, checkValidationRules(reflect.TypeOf(struct {
+		Items []struct {
+			Value int `json:"value" validation:"regexp=a"`
+		} `json:"items"`
+	}{})), "items[]: invalid validation rule 'regexp=a' on field 'value': unsupported type 'int'")
+
+	require.PanicsWithError(t, "reqTemplate has invalid validation rules: invalid validation rule 'unknown' on field 'Value': unknown rule", func() {
+		NewFunction(struct {
+			Value string `validation:"unknown"`
+		}{}, func(ctx context.Context, req interface{}) (interface{}, error) {
+			return nil, nil
+		})
+	})
+}
+
+func TestFunction_Validation(t *testing.T) {
+	f := NewFunction(validationTestItem{}, func(ctx context.Context, req interface{}) (interface{}, error) {
+		return nil, nil
+	})
+
+	out, err := f.Handler(context.Background(), events.APIGatewayProxyRequest{Body: `{"name":""}`})
+	require.NoError(t, err)
+	require.Equal(t, http.StatusUnprocessableEntity, out.StatusCode)
+
+	errResp := &ErrorResponse{}
+	require.NoError(t, json.Unmarshal([]byte(out.Body), errResp))
+	require.Equal(t, &ErrorResponse{
+		StatusCode:    http.StatusUnprocessableEntity,
+		PublicMessage: "validation-failed",
+		ValidationErrors: []*ValidationError{
+			{Field: "name", Rule: "required"},
+			{Field: "name", Rule: "regexp", Param: "^[a-z]{1,3}$"},
+		},
+	}, errResp)
+
+	f = NewFunction(struct {
+		Email string `json:"email" validate:"required,email"`
+	}{}, func(ctx context.Context, req interface{}) (interface{}, error) {
+		return nil, nil
+	})
+
+	out, err = f.Handler(context.Background(), events.APIGatewayProxyRequest{Body: `{"email":""}`})
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, out.StatusCode)
+}